@@ -0,0 +1,88 @@
+// Compare the tree walking evaluator with the bytecode vm on tight while loops
+//
+// usage: go test ./bench -bench .
+package bench
+
+import (
+	"io"
+	"test1/compiler"
+	"test1/evaluator"
+	"test1/parser"
+	"test1/tokenizer"
+	"test1/vm"
+	"testing"
+)
+
+// programs that spend their time in while loops
+var programs = []struct {
+	name   string
+	source string
+}{
+	{"module loop", `
+i = 0
+total = 0
+while i < 100000:
+   total = total + i * 2 - 1
+   i = i + 1
+`},
+	{"function loop", `
+def count(n):
+   i = 0
+   total = 0.0
+   while i < n:
+      total = total + i / 2.0
+      i = i + 1
+   return total
+count(100000)
+`},
+	{"calls in loop", `
+def add(a, b):
+   return a + b
+i = 0
+while i < 20000:
+   i = add(i, 1)
+`},
+}
+
+func parse(b *testing.B, source string) *parser.Module {
+	module, err := parser.Parse(tokenizer.NewLexer(source))
+	if err != nil {
+		b.Fatal(err)
+	}
+	return module
+}
+
+func BenchmarkEvaluator(b *testing.B) {
+	for _, p := range programs {
+		b.Run(p.name, func(b *testing.B) {
+			module := parse(b, p.source)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				e := evaluator.New()
+				e.Stdout = io.Discard
+				if err := e.Run(module); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkVM(b *testing.B) {
+	for _, p := range programs {
+		b.Run(p.name, func(b *testing.B) {
+			code, err := compiler.Compile(parse(b, p.source))
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				m := vm.New()
+				m.Stdout = io.Discard
+				if err := m.Run(code); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+// Bytecode produced by the compiler and run by the vm
+package compiler
+
+import (
+	"fmt"
+	"strings"
+	"test1/object"
+	"test1/tokenizer"
+)
+
+type Opcode uint8
+
+const (
+	LOAD_CONST            Opcode = iota // push Consts[arg]
+	LOAD_NAME                           // push module level Names[arg]
+	STORE_NAME                          // pop into module level Names[arg]
+	LOAD_FAST                           // push local slot arg
+	STORE_FAST                          // pop into local slot arg
+	LOAD_GLOBAL                         // push global Names[arg] from inside a function
+	STORE_GLOBAL                        // pop into global Names[arg] from inside a function
+	LOAD_DEREF                          // push the value of cell arg
+	STORE_DEREF                         // pop into cell arg
+	LOAD_CLOSURE                        // push cell arg itself, used by MAKE_FUNCTION
+	UNARY_OP                            // replace top with unary op arg (token category) applied to it
+	BINARY_OP                           // pop 2, push result of op arg (token category)
+	COMPARE_OP                          // pop 2, push the result of comparison arg (token category)
+	JUMP                                // continue at instruction arg
+	POP_JUMP_IF_FALSE                   // pop, continue at instruction arg when it is not true
+	JUMP_IF_TRUE_OR_POP                 // continue at arg keeping top when it is true, else pop it
+	JUMP_IF_FALSE_OR_POP                // continue at arg keeping top when it is not true, else pop it
+	CALL                                // pop arg arguments and the function, push the result
+	RETURN_VALUE                        // pop and return from the function
+	POP_TOP                             // pop and discard
+	MAKE_FUNCTION                       // pop code and arg cells, push a function
+	DUP_TOP                             // push the top value again
+	ROT_TWO                             // swap the 2 top values
+	ROT_THREE                           // move the top value under the 2 below it
+	SETUP_FINALLY                       // push a block, an exception inside it jump to arg with the exception pushed
+	POP_BLOCK                           // pop the block of the last SETUP_FINALLY
+	PUSH_EXC_INFO                       // the exception on top become the one being handled
+	POP_EXCEPT                          // restore the exception handled before PUSH_EXC_INFO
+	JUMP_IF_NOT_EXC_MATCH               // pop the class and the exception, continue at arg when they do not match
+	RAISE_VARARGS                       // pop arg values: the exception and the cause, raise the handled one when arg is 0
+	RERAISE                             // pop an exception and raise it again without adding to its traceback
+	PRINT_EXPR                          // pop and print its repr unless it is None, for the REPL
+	BUILD_LIST                          // pop arg values, push a list holding them
+	BUILD_SLICE                         // pop arg (2 or 3) slice bounds, push a slice
+	BINARY_SUBSCR                       // pop the key and the container, push container[key]
+	STORE_SUBSCR                        // pop the key, the container and the value, container[key] = value
+	DELETE_SUBSCR                       // pop the key and the container, del container[key]
+	LOAD_ATTR                           // replace top with its attribute Names[arg]
+	STORE_ATTR                          // pop the object and the value, set attribute Names[arg]
+	DELETE_ATTR                         // pop the object, delete attribute Names[arg]
+	DELETE_NAME                         // unbind module level Names[arg]
+	DELETE_FAST                         // unbind local slot arg
+	DELETE_GLOBAL                       // unbind global Names[arg] from inside a function
+	DELETE_DEREF                        // unbind cell arg
+	BUILD_MAP                           // pop arg keys and values, key first, push a dict holding them
+	BUILD_TUPLE                         // pop arg values, push a tuple holding them
+	BUILD_SET                           // pop arg values, push a set holding them
+	LIST_APPEND                         // pop a value and append it to the list below it
+	LIST_EXTEND                         // pop an iterable and add its items to the list below it
+	SET_ADD                             // pop a value and add it to the set below it
+	SET_UPDATE                          // pop an iterable and add its items to the set below it
+	LIST_TO_TUPLE                       // replace the list on top with a tuple of its items
+	UNPACK_SEQUENCE                     // pop an iterable of arg items, push them with the first on top
+	UNPACK_EX                           // like UNPACK_SEQUENCE with a starred target, arg is before | after<<8
+	GET_ITER                            // replace top with an iterator over it
+	FOR_ITER                            // push the next item of the iterator on top, pop it and continue at arg once exhausted
+	CALL_KW                             // pop a tuple of keyword names, arg values and the function, the last values are the keyword arguments
+	IMPORT_NAME                         // push the module Names[arg]
+	CALL_FUNCTION_EX                    // pop a dict of keyword arguments when arg is 1, an iterable of positional arguments and the function, push the result
+	DICT_MERGE                          // pop a mapping and add its items to the dict below it, the function called is arg values below the dict
+	SET_DEFAULTS                        // pop the function and the value below it, set its defaults (arg 1) or its keyword-only defaults (arg 2), push the function
+)
+
+var opcodeNames = [...]string{
+	LOAD_CONST: "LOAD_CONST", LOAD_NAME: "LOAD_NAME", STORE_NAME: "STORE_NAME",
+	LOAD_FAST: "LOAD_FAST", STORE_FAST: "STORE_FAST", LOAD_GLOBAL: "LOAD_GLOBAL",
+	STORE_GLOBAL: "STORE_GLOBAL", LOAD_DEREF: "LOAD_DEREF", STORE_DEREF: "STORE_DEREF",
+	LOAD_CLOSURE: "LOAD_CLOSURE", UNARY_OP: "UNARY_OP", BINARY_OP: "BINARY_OP",
+	COMPARE_OP: "COMPARE_OP", JUMP: "JUMP", POP_JUMP_IF_FALSE: "POP_JUMP_IF_FALSE",
+	CALL: "CALL", RETURN_VALUE: "RETURN_VALUE", POP_TOP: "POP_TOP",
+	MAKE_FUNCTION: "MAKE_FUNCTION", DUP_TOP: "DUP_TOP", SETUP_FINALLY: "SETUP_FINALLY",
+	POP_BLOCK: "POP_BLOCK", PUSH_EXC_INFO: "PUSH_EXC_INFO", POP_EXCEPT: "POP_EXCEPT",
+	JUMP_IF_NOT_EXC_MATCH: "JUMP_IF_NOT_EXC_MATCH", RAISE_VARARGS: "RAISE_VARARGS",
+	RERAISE: "RERAISE", PRINT_EXPR: "PRINT_EXPR", BUILD_LIST: "BUILD_LIST",
+	BUILD_SLICE: "BUILD_SLICE", BINARY_SUBSCR: "BINARY_SUBSCR", STORE_SUBSCR: "STORE_SUBSCR",
+	DELETE_SUBSCR: "DELETE_SUBSCR", LOAD_ATTR: "LOAD_ATTR", STORE_ATTR: "STORE_ATTR",
+	DELETE_ATTR: "DELETE_ATTR", DELETE_NAME: "DELETE_NAME", DELETE_FAST: "DELETE_FAST",
+	DELETE_GLOBAL: "DELETE_GLOBAL", DELETE_DEREF: "DELETE_DEREF", BUILD_MAP: "BUILD_MAP",
+	BUILD_TUPLE: "BUILD_TUPLE", BUILD_SET: "BUILD_SET", LIST_APPEND: "LIST_APPEND",
+	LIST_EXTEND: "LIST_EXTEND", SET_ADD: "SET_ADD", SET_UPDATE: "SET_UPDATE",
+	LIST_TO_TUPLE: "LIST_TO_TUPLE", UNPACK_SEQUENCE: "UNPACK_SEQUENCE", UNPACK_EX: "UNPACK_EX",
+	ROT_TWO: "ROT_TWO", ROT_THREE: "ROT_THREE", GET_ITER: "GET_ITER", FOR_ITER: "FOR_ITER", JUMP_IF_TRUE_OR_POP: "JUMP_IF_TRUE_OR_POP",
+	JUMP_IF_FALSE_OR_POP: "JUMP_IF_FALSE_OR_POP", CALL_KW: "CALL_KW", IMPORT_NAME: "IMPORT_NAME",
+	CALL_FUNCTION_EX: "CALL_FUNCTION_EX", DICT_MERGE: "DICT_MERGE", SET_DEFAULTS: "SET_DEFAULTS",
+}
+
+func (op Opcode) String() string {
+	if int(op) < len(opcodeNames) {
+		return opcodeNames[op]
+	}
+	return fmt.Sprintf("OP_%d", op)
+}
+
+// one instruction packed in 32 bits, the opcode in the low 8 bits and the
+// argument in the high 24 bits
+type Instr uint32
+
+const maxArg = 1<<24 - 1
+
+func makeInstr(op Opcode, arg int) Instr {
+	return Instr(uint32(arg)<<8 | uint32(op))
+}
+
+func (i Instr) Op() Opcode {
+	return Opcode(i & 0xff)
+}
+
+func (i Instr) Arg() int {
+	return int(i >> 8)
+}
+
+// compiled body of the module or of one function
+type Code struct {
+	Name      string
+	Instrs    []Instr
+	Pos       []tokenizer.Token // token each instruction came from, for error messages
+	Consts    []object.Value    // constants, including Code of nested functions
+	Names     []string          // global names used by LOAD_NAME, LOAD_GLOBAL ...
+	Varnames  []string          // local slots, the parameters come first
+	Cellvars  []string          // locals captured by nested functions
+	Freevars  []string          // names captured from enclosing functions
+	Params    object.Params     // parameters of a function, bound to the first Varnames
+	StackSize int               // maximum depth of the operand stack
+}
+
+// readable listing of the code and of the functions it contains
+func (c *Code) Disassemble() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "code %s:\n", c.Name)
+	for i, in := range c.Instrs {
+		fmt.Fprintf(&b, "%4d %4d %-18s %d", c.Pos[i].Row, i, in.Op(), in.Arg())
+		switch in.Op() {
+		case LOAD_CONST:
+			fmt.Fprintf(&b, " (%s)", c.Consts[in.Arg()].Repr())
+		case LOAD_NAME, STORE_NAME, LOAD_GLOBAL, STORE_GLOBAL, DELETE_NAME, DELETE_GLOBAL,
+			LOAD_ATTR, STORE_ATTR, DELETE_ATTR, IMPORT_NAME:
+			fmt.Fprintf(&b, " (%s)", c.Names[in.Arg()])
+		case LOAD_FAST, STORE_FAST, DELETE_FAST:
+			fmt.Fprintf(&b, " (%s)", c.Varnames[in.Arg()])
+		case LOAD_DEREF, STORE_DEREF, LOAD_CLOSURE, DELETE_DEREF:
+			fmt.Fprintf(&b, " (%s)", c.CellName(in.Arg()))
+		case UNARY_OP, BINARY_OP, COMPARE_OP:
+			fmt.Fprintf(&b, " (%s)", strings.Trim(tokenizer.Describe(in.Arg()), "'"))
+		}
+		b.WriteString("\n")
+	}
+	for _, k := range c.Consts {
+		if inner, ok := k.(*Code); ok {
+			b.WriteString(inner.Disassemble())
+		}
+	}
+	return b.String()
+}
+
+// cells are numbered with the cellvars first then the freevars
+func (c *Code) CellName(i int) string {
+	if i < len(c.Cellvars) {
+		return c.Cellvars[i]
+	}
+	return c.Freevars[i-len(c.Cellvars)]
+}
+
+var CodeType = &object.Type{Name: "code"}
+
+func (c *Code) Type() *object.Type { return CodeType }
+func (c *Code) Repr() string       { return "<code " + c.Name + ">" }
+func (c *Code) Truth() bool        { return true }
@@ -0,0 +1,1240 @@
+// Compiler that turn the AST into bytecode for the vm
+package compiler
+
+import (
+	"test1/object"
+	"test1/parser"
+	"test1/symtable"
+	"test1/tokenizer"
+)
+
+type compiler struct {
+	code    *Code
+	symbols *symtable.Symbols
+	pos     tokenizer.Token // token of the node being compiled
+	depth   int             // current depth of the operand stack
+	fblocks []fblock        // blocks a return, break or continue must leave, innermost last
+
+	interactive bool // echo module level expression statements, for the REPL
+}
+
+// kind of block that need cleanup instructions when a return leave it, or
+// that a break and a continue jump out of
+type fblockKind int
+
+const (
+	tryExcept      fblockKind = iota // body of a try with except clauses
+	tryFinally                       // body of a try with a finally block
+	handlerCleanup                   // except clause or finally block run for an exception
+	handlerName                      // except clause with an as name, unbound when it ends
+	whileLoop                        // body of a while loop
+	forLoop                          // body of a for loop, its iterator is on the stack
+	popValue                         // finally body compiled for a return, the return value is on the stack
+)
+
+type fblock struct {
+	kind    fblockKind
+	finally []parser.Stmt // the finally body of a tryFinally block
+	name    string        // the as name of a handlerName block
+	loop    *loop         // the jumps of a whileLoop or forLoop block
+}
+
+// jump targets of a loop being compiled
+type loop struct {
+	top    int   // where continue jump
+	breaks []int // jumps of break, patched to the end of the loop
+}
+
+// compile a module, the returned code run the module body
+func Compile(m *parser.Module) (*Code, error) {
+	return compile(m, false)
+}
+
+// compile statements typed at the REPL, the code print the repr of the
+// value of module level expression statements unless it is None
+func CompileInteractive(m *parser.Module) (*Code, error) {
+	return compile(m, true)
+}
+
+// compile a single expression, the code return its value
+func CompileExpr(x parser.Expr) (*Code, error) {
+	m := &parser.Module{Body: []parser.Stmt{&parser.ExprStmt{Token: x.Pos(), X: x}}}
+	symbols, err := symtable.Resolve(m)
+	if err != nil {
+		return nil, err
+	}
+	c := newCompiler("<module>", symbols)
+	c.pos = x.Pos()
+	if err := c.expr(x); err != nil {
+		return nil, err
+	}
+	c.emit(RETURN_VALUE, 0)
+	return c.code, nil
+}
+
+func compile(m *parser.Module, interactive bool) (*Code, error) {
+	symbols, err := symtable.Resolve(m)
+	if err != nil {
+		return nil, err
+	}
+	c := newCompiler("<module>", symbols)
+	c.interactive = interactive
+	if err := c.block(m.Body); err != nil {
+		return nil, err
+	}
+	c.emit(LOAD_CONST, c.constant(object.None))
+	c.emit(RETURN_VALUE, 0)
+	return c.code, nil
+}
+
+func newCompiler(name string, symbols *symtable.Symbols) *compiler {
+	c := &compiler{code: &Code{Name: name}, symbols: symbols}
+	// parameters take the first local slots
+	for _, param := range symbols.Params() {
+		c.varname(param)
+	}
+	for _, name := range symbols.Locals() {
+		if !symbols.IsCell(name) {
+			c.varname(name)
+		}
+	}
+	c.code.Cellvars = symbols.Cellvars()
+	c.code.Freevars = symbols.Freevars()
+	return c
+}
+
+func (c *compiler) errorf(format string, args ...interface{}) error {
+	exc := object.Errorf(object.SyntaxError, format, args...)
+	exc.Row, exc.Column = c.pos.Row, c.pos.Column
+	return exc
+}
+
+// append an instruction and return its index
+func (c *compiler) emit(op Opcode, arg int) int {
+	if arg > maxArg {
+		panic("compiler: instruction argument too large")
+	}
+	c.code.Instrs = append(c.code.Instrs, makeInstr(op, arg))
+	c.code.Pos = append(c.code.Pos, c.pos)
+	c.depth += stackEffect(op, arg)
+	if c.depth > c.code.StackSize {
+		c.code.StackSize = c.depth
+	}
+	return len(c.code.Instrs) - 1
+}
+
+// change the target of a jump emitted before its target was known
+func (c *compiler) patch(at int, target int) {
+	c.code.Instrs[at] = makeInstr(c.code.Instrs[at].Op(), target)
+}
+
+// stack depth at the start of an exception handler, which is not reached by
+// falling through from the instruction before it
+func (c *compiler) setDepth(depth int) {
+	c.depth = depth
+	if c.depth > c.code.StackSize {
+		c.code.StackSize = c.depth
+	}
+}
+
+// how many values an instruction push (positive) or pop (negative)
+func stackEffect(op Opcode, arg int) int {
+	switch op {
+	case LOAD_CONST, LOAD_NAME, LOAD_FAST, LOAD_GLOBAL, LOAD_DEREF, LOAD_CLOSURE, IMPORT_NAME:
+		return 1
+	case FOR_ITER:
+		return 1 // when it does not jump, the iterator is popped when it does
+	// JUMP_IF_TRUE_OR_POP and JUMP_IF_FALSE_OR_POP pop when they do not jump,
+	// the operand evaluated after them take the place of the popped one
+	case STORE_NAME, STORE_FAST, STORE_GLOBAL, STORE_DEREF, BINARY_OP, COMPARE_OP,
+		POP_JUMP_IF_FALSE, JUMP_IF_TRUE_OR_POP, JUMP_IF_FALSE_OR_POP, RETURN_VALUE, POP_TOP, RERAISE, PRINT_EXPR, BINARY_SUBSCR, DELETE_ATTR,
+		LIST_APPEND, LIST_EXTEND, SET_ADD, SET_UPDATE:
+		return -1
+	case DUP_TOP:
+		return 1
+	case JUMP_IF_NOT_EXC_MATCH, DELETE_SUBSCR, STORE_ATTR:
+		return -2
+	case STORE_SUBSCR:
+		return -3
+	case CALL, MAKE_FUNCTION, RAISE_VARARGS:
+		return -arg
+	case CALL_KW, CALL_FUNCTION_EX:
+		return -arg - 1
+	case DICT_MERGE, SET_DEFAULTS:
+		return -1
+	case BUILD_LIST, BUILD_SLICE, BUILD_TUPLE, BUILD_SET:
+		return 1 - arg
+	case UNPACK_SEQUENCE:
+		return arg - 1
+	case UNPACK_EX:
+		return arg&0xff + arg>>8
+	case BUILD_MAP:
+		return 1 - 2*arg
+	}
+	return 0
+}
+
+func (c *compiler) constant(v object.Value) int {
+	for i, k := range c.code.Consts {
+		// only reuse constants of the same type, 1 and 1.0 are different constants
+		if k.Type() == v.Type() && k == v {
+			return i
+		}
+	}
+	c.code.Consts = append(c.code.Consts, v)
+	return len(c.code.Consts) - 1
+}
+
+func (c *compiler) name(name string) int {
+	for i, n := range c.code.Names {
+		if n == name {
+			return i
+		}
+	}
+	c.code.Names = append(c.code.Names, name)
+	return len(c.code.Names) - 1
+}
+
+func (c *compiler) varname(name string) int {
+	for i, n := range c.code.Varnames {
+		if n == name {
+			return i
+		}
+	}
+	c.code.Varnames = append(c.code.Varnames, name)
+	return len(c.code.Varnames) - 1
+}
+
+func (c *compiler) cell(name string) int {
+	for i, n := range c.code.Cellvars {
+		if n == name {
+			return i
+		}
+	}
+	for i, n := range c.code.Freevars {
+		if n == name {
+			return len(c.code.Cellvars) + i
+		}
+	}
+	panic("compiler: no cell for " + name)
+}
+
+func (c *compiler) loadName(name string) {
+	switch c.symbols.Scope(name) {
+	case symtable.ScopeName:
+		c.emit(LOAD_NAME, c.name(name))
+	case symtable.ScopeLocal:
+		c.emit(LOAD_FAST, c.varname(name))
+	case symtable.ScopeCell:
+		c.emit(LOAD_DEREF, c.cell(name))
+	case symtable.ScopeGlobal:
+		c.emit(LOAD_GLOBAL, c.name(name))
+	}
+}
+
+func (c *compiler) deleteName(name string) {
+	switch c.symbols.Scope(name) {
+	case symtable.ScopeName:
+		c.emit(DELETE_NAME, c.name(name))
+	case symtable.ScopeLocal:
+		c.emit(DELETE_FAST, c.varname(name))
+	case symtable.ScopeCell:
+		c.emit(DELETE_DEREF, c.cell(name))
+	case symtable.ScopeGlobal:
+		c.emit(DELETE_GLOBAL, c.name(name))
+	}
+}
+
+func (c *compiler) storeName(name string) {
+	switch c.symbols.Scope(name) {
+	case symtable.ScopeName:
+		c.emit(STORE_NAME, c.name(name))
+	case symtable.ScopeLocal:
+		c.emit(STORE_FAST, c.varname(name))
+	case symtable.ScopeCell:
+		c.emit(STORE_DEREF, c.cell(name))
+	case symtable.ScopeGlobal:
+		c.emit(STORE_GLOBAL, c.name(name))
+	}
+}
+
+/*#################
+### STATEMENTS ###
+#################*/
+
+func (c *compiler) block(body []parser.Stmt) error {
+	for _, s := range body {
+		if err := c.stmt(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *compiler) stmt(stmt parser.Stmt) error {
+	c.pos = stmt.Pos()
+	switch s := stmt.(type) {
+	case *parser.Assign:
+		if err := c.expr(s.Value); err != nil {
+			return err
+		}
+		for i, target := range s.Targets {
+			if i < len(s.Targets)-1 {
+				c.emit(DUP_TOP, 0)
+			}
+			if err := c.store(target); err != nil {
+				return err
+			}
+		}
+	case *parser.Delete:
+		for _, target := range s.Targets {
+			if err := c.delete(target); err != nil {
+				return err
+			}
+		}
+	case *parser.ExprStmt:
+		if err := c.expr(s.X); err != nil {
+			return err
+		}
+		if c.interactive {
+			c.emit(PRINT_EXPR, 0)
+		} else {
+			c.emit(POP_TOP, 0)
+		}
+	case *parser.Import:
+		c.pos = s.Token
+		for _, alias := range s.Names {
+			c.emit(IMPORT_NAME, c.name(alias.Name))
+			c.storeName(alias.AsName)
+		}
+	case *parser.Pass, *parser.Global, *parser.Nonlocal:
+		// declarations are handled by the symtable
+	case *parser.Return:
+		if s.Value != nil {
+			if err := c.expr(s.Value); err != nil {
+				return err
+			}
+		} else {
+			c.emit(LOAD_CONST, c.constant(object.None))
+		}
+		c.pos = s.Token
+		// the code after it is not reached, it is compiled at the same depth
+		depth := c.depth
+		if _, err := c.unwind(s); err != nil {
+			return err
+		}
+		c.emit(RETURN_VALUE, 0)
+		c.setDepth(depth - 1)
+	case *parser.Break, *parser.Continue:
+		// the code after it is not reached, it is compiled at the same depth
+		depth := c.depth
+		l, err := c.unwind(s)
+		if err != nil {
+			return err
+		}
+		if _, ok := s.(*parser.Break); ok {
+			l.breaks = append(l.breaks, c.emit(JUMP, 0))
+		} else {
+			c.emit(JUMP, l.top)
+		}
+		c.setDepth(depth)
+	case *parser.If:
+		if err := c.expr(s.Cond); err != nil {
+			return err
+		}
+		jumpElse := c.emit(POP_JUMP_IF_FALSE, 0)
+		if err := c.block(s.Body); err != nil {
+			return err
+		}
+		if s.Else == nil {
+			c.patch(jumpElse, len(c.code.Instrs))
+			return nil
+		}
+		jumpEnd := c.emit(JUMP, 0)
+		c.patch(jumpElse, len(c.code.Instrs))
+		if err := c.block(s.Else); err != nil {
+			return err
+		}
+		c.patch(jumpEnd, len(c.code.Instrs))
+	case *parser.While:
+		return c.whilestmt(s)
+	case *parser.For:
+		return c.forstmt(s)
+	case *parser.FuncDef:
+		return c.funcdef(s)
+	case *parser.Try:
+		if s.Finally != nil {
+			return c.tryfinally(s)
+		}
+		return c.tryexcept(s)
+	case *parser.Raise:
+		n := 0
+		for _, x := range []parser.Expr{s.Exc, s.Cause} {
+			if x != nil {
+				if err := c.expr(x); err != nil {
+					return err
+				}
+				n++
+			}
+		}
+		c.pos = s.Token
+		c.emit(RAISE_VARARGS, n)
+	default:
+		return c.errorf("unknown statement %T", stmt)
+	}
+	return nil
+}
+
+// emit the cleanup of the blocks that exit leave, the finally bodies are
+// compiled again at each exit. A return leave every block, a break and a
+// continue stop at the innermost loop and return it, a break also pop the
+// iterator of a for loop. The value of a return stay on top of the stack,
+// what the blocks leave under it is popped with ROT_TWO and POP_TOP
+func (c *compiler) unwind(exit parser.Stmt) (*loop, error) {
+	_, returning := exit.(*parser.Return)
+	_, breaking := exit.(*parser.Break)
+	fblocks := c.fblocks
+	defer func() { c.fblocks = fblocks }()
+	for i := len(fblocks) - 1; i >= 0; i-- {
+		// a copy, the finally bodies compiled below push their own blocks
+		c.fblocks = append([]fblock(nil), fblocks[:i]...)
+		switch fblocks[i].kind {
+		case tryExcept:
+			c.emit(POP_BLOCK, 0)
+		case tryFinally:
+			c.emit(POP_BLOCK, 0)
+			var err error
+			if returning {
+				// a break or a continue in the finally body drop the value
+				err = c.fblock(popValue, nil, fblocks[i].finally)
+			} else {
+				err = c.block(fblocks[i].finally)
+			}
+			if err != nil {
+				return nil, err
+			}
+			c.pos = exit.Pos()
+		case handlerCleanup:
+			c.emit(POP_BLOCK, 0)
+			c.emit(POP_EXCEPT, 0)
+			c.popUnder(returning) // the exception being handled
+		case popValue:
+			c.popUnder(returning)
+		case handlerName:
+			c.emit(POP_BLOCK, 0)
+			c.unbindHandlerName(fblocks[i].name)
+		case whileLoop, forLoop:
+			if returning {
+				if fblocks[i].kind == forLoop {
+					c.popUnder(true) // the iterator
+				}
+				continue
+			}
+			if breaking && fblocks[i].kind == forLoop {
+				c.emit(POP_TOP, 0)
+			}
+			return fblocks[i].loop, nil
+		}
+	}
+	return nil, nil
+}
+
+// pop the value on top of the stack, or the one under it when the value of
+// a return is on top
+func (c *compiler) popUnder(returning bool) {
+	if returning {
+		c.emit(ROT_TWO, 0)
+	}
+	c.emit(POP_TOP, 0)
+}
+
+// compile body inside a block that a return must clean up
+func (c *compiler) fblock(kind fblockKind, finally []parser.Stmt, body []parser.Stmt) error {
+	c.fblocks = append(c.fblocks, fblock{kind: kind, finally: finally})
+	err := c.block(body)
+	c.fblocks = c.fblocks[:len(c.fblocks)-1]
+	return err
+}
+
+// compile the body of a loop, break and continue inside it jump to l
+func (c *compiler) loopBody(kind fblockKind, l *loop, body []parser.Stmt) error {
+	c.fblocks = append(c.fblocks, fblock{kind: kind, loop: l})
+	err := c.block(body)
+	c.fblocks = c.fblocks[:len(c.fblocks)-1]
+	return err
+}
+
+// while loop, the else block run when the condition become false:
+//
+//	top:
+//	    <cond>
+//	    POP_JUMP_IF_FALSE orelse
+//	    <body>                    continue: JUMP top
+//	    JUMP top
+//	orelse:
+//	    <else>
+//	end:                          break: JUMP end
+func (c *compiler) whilestmt(s *parser.While) error {
+	l := &loop{top: len(c.code.Instrs)}
+	if err := c.expr(s.Cond); err != nil {
+		return err
+	}
+	jumpElse := c.emit(POP_JUMP_IF_FALSE, 0)
+	if err := c.loopBody(whileLoop, l, s.Body); err != nil {
+		return err
+	}
+	c.emit(JUMP, l.top)
+	c.patch(jumpElse, len(c.code.Instrs))
+	if err := c.block(s.Else); err != nil {
+		return err
+	}
+	for _, at := range l.breaks {
+		c.patch(at, len(c.code.Instrs))
+	}
+	return nil
+}
+
+// for loop, the iterator stay on the stack while the loop run and the else
+// block run when it is exhausted:
+//
+//	    <iter>
+//	    GET_ITER
+//	top:
+//	    FOR_ITER orelse
+//	    <store target>
+//	    <body>                    continue: JUMP top
+//	    JUMP top
+//	orelse:                       iterator popped
+//	    <else>
+//	end:                          break: POP_TOP, JUMP end
+func (c *compiler) forstmt(s *parser.For) error {
+	if err := c.expr(s.Iter); err != nil {
+		return err
+	}
+	base := c.depth - 1
+	c.pos = s.Token
+	c.emit(GET_ITER, 0)
+	l := &loop{top: c.emit(FOR_ITER, 0)}
+	if err := c.store(s.Target); err != nil {
+		return err
+	}
+	if err := c.loopBody(forLoop, l, s.Body); err != nil {
+		return err
+	}
+	c.pos = s.Token
+	c.emit(JUMP, l.top)
+	c.patch(l.top, len(c.code.Instrs))
+	c.setDepth(base)
+	if err := c.block(s.Else); err != nil {
+		return err
+	}
+	for _, at := range l.breaks {
+		c.patch(at, len(c.code.Instrs))
+	}
+	return nil
+}
+
+// try with except clauses, the else block run when the body raise nothing:
+//
+//	    SETUP_FINALLY handler
+//	    <body>
+//	    POP_BLOCK
+//	    <else>
+//	    JUMP end
+//	handler:                      exception pushed
+//	    PUSH_EXC_INFO
+//	    SETUP_FINALLY cleanup
+//	    DUP_TOP                   for each clause
+//	    <type>
+//	    JUMP_IF_NOT_EXC_MATCH next
+//	    DUP_TOP                   with "as" name
+//	    STORE name
+//	    SETUP_FINALLY unbind
+//	    <clause body>
+//	    POP_BLOCK                 with "as" name
+//	    <unbind name>
+//	    POP_BLOCK
+//	    POP_EXCEPT
+//	    POP_TOP
+//	    JUMP end
+//	unbind:                       with "as" name, exception raised in the clause pushed
+//	    <unbind name>
+//	    RERAISE
+//	next:
+//	    POP_BLOCK                 no clause matched
+//	    POP_EXCEPT
+//	    RERAISE
+//	cleanup:                      exception raised in a clause pushed
+//	    POP_EXCEPT
+//	    RERAISE
+//	end:
+func (c *compiler) tryexcept(s *parser.Try) error {
+	base := c.depth
+	setup := c.emit(SETUP_FINALLY, 0)
+	if err := c.fblock(tryExcept, nil, s.Body); err != nil {
+		return err
+	}
+	c.emit(POP_BLOCK, 0)
+	if err := c.block(s.Else); err != nil {
+		return err
+	}
+	ends := []int{c.emit(JUMP, 0)}
+
+	c.patch(setup, len(c.code.Instrs))
+	c.setDepth(base + 1)
+	c.pos = s.Handlers[0].Token
+	c.emit(PUSH_EXC_INFO, 0)
+	cleanup := c.emit(SETUP_FINALLY, 0)
+	for _, h := range s.Handlers {
+		c.pos = h.Token
+		jumpNext := -1
+		if h.Type != nil {
+			c.emit(DUP_TOP, 0)
+			if err := c.expr(h.Type); err != nil {
+				return err
+			}
+			c.pos = h.Token
+			jumpNext = c.emit(JUMP_IF_NOT_EXC_MATCH, 0)
+		}
+		unbind := -1
+		if h.Name == "" {
+			if err := c.fblock(handlerCleanup, nil, h.Body); err != nil {
+				return err
+			}
+		} else {
+			c.emit(DUP_TOP, 0)
+			c.storeName(h.Name)
+			unbind = c.emit(SETUP_FINALLY, 0)
+			c.fblocks = append(c.fblocks, fblock{kind: handlerCleanup}, fblock{kind: handlerName, name: h.Name})
+			err := c.block(h.Body)
+			c.fblocks = c.fblocks[:len(c.fblocks)-2]
+			if err != nil {
+				return err
+			}
+			c.pos = h.Token
+			c.emit(POP_BLOCK, 0)
+			c.unbindHandlerName(h.Name)
+		}
+		c.emit(POP_BLOCK, 0)
+		c.emit(POP_EXCEPT, 0)
+		c.emit(POP_TOP, 0)
+		ends = append(ends, c.emit(JUMP, 0))
+		if unbind >= 0 {
+			c.patch(unbind, len(c.code.Instrs))
+			c.setDepth(base + 2)
+			c.unbindHandlerName(h.Name)
+			c.emit(RERAISE, 0)
+		}
+		c.setDepth(base + 1)
+		if jumpNext >= 0 {
+			c.patch(jumpNext, len(c.code.Instrs))
+		}
+	}
+	c.emit(POP_BLOCK, 0)
+	c.emit(POP_EXCEPT, 0)
+	c.emit(RERAISE, 0)
+
+	c.patch(cleanup, len(c.code.Instrs))
+	c.setDepth(base + 2)
+	c.emit(POP_EXCEPT, 0)
+	c.emit(RERAISE, 0)
+
+	for _, at := range ends {
+		c.patch(at, len(c.code.Instrs))
+	}
+	c.setDepth(base)
+	return nil
+}
+
+// unbind the as name of an except clause like CPython, it is set to None
+// first so a name the clause deleted does not raise
+func (c *compiler) unbindHandlerName(name string) {
+	c.emit(LOAD_CONST, c.constant(object.None))
+	c.storeName(name)
+	c.deleteName(name)
+}
+
+// try with a finally block, the finally body is compiled once for each way out:
+//
+//	    SETUP_FINALLY handler
+//	    <try except else>
+//	    POP_BLOCK
+//	    <finally>
+//	    JUMP end
+//	handler:                      exception pushed
+//	    PUSH_EXC_INFO
+//	    SETUP_FINALLY cleanup
+//	    <finally>
+//	    POP_BLOCK
+//	    POP_EXCEPT
+//	    RERAISE
+//	cleanup:                      exception raised in finally pushed
+//	    POP_EXCEPT
+//	    RERAISE
+//	end:
+func (c *compiler) tryfinally(s *parser.Try) error {
+	base := c.depth
+	setup := c.emit(SETUP_FINALLY, 0)
+	c.fblocks = append(c.fblocks, fblock{kind: tryFinally, finally: s.Finally})
+	var err error
+	if len(s.Handlers) > 0 {
+		err = c.tryexcept(s)
+	} else {
+		err = c.block(s.Body)
+	}
+	c.fblocks = c.fblocks[:len(c.fblocks)-1]
+	if err != nil {
+		return err
+	}
+	c.emit(POP_BLOCK, 0)
+	if err := c.block(s.Finally); err != nil {
+		return err
+	}
+	end := c.emit(JUMP, 0)
+
+	c.patch(setup, len(c.code.Instrs))
+	c.setDepth(base + 1)
+	c.emit(PUSH_EXC_INFO, 0)
+	cleanup := c.emit(SETUP_FINALLY, 0)
+	if err := c.fblock(handlerCleanup, nil, s.Finally); err != nil {
+		return err
+	}
+	c.emit(POP_BLOCK, 0)
+	c.emit(POP_EXCEPT, 0)
+	c.emit(RERAISE, 0)
+
+	c.patch(cleanup, len(c.code.Instrs))
+	c.setDepth(base + 2)
+	c.emit(POP_EXCEPT, 0)
+	c.emit(RERAISE, 0)
+
+	c.patch(end, len(c.code.Instrs))
+	c.setDepth(base)
+	return nil
+}
+
+// compile the function body into its own code, then emit the instructions
+// that build the function and bind its name
+func (c *compiler) funcdef(s *parser.FuncDef) error {
+	err := c.function(s, s.Name, s.Params, func(fc *compiler) error {
+		if err := fc.block(s.Body); err != nil {
+			return err
+		}
+		fc.emit(LOAD_CONST, fc.constant(object.None))
+		fc.emit(RETURN_VALUE, 0)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	c.storeName(s.Name)
+	return nil
+}
+
+// a lambda is a function returning the value of its body
+func (c *compiler) lambda(x *parser.Lambda) error {
+	return c.function(x, "<lambda>", x.Params, func(fc *compiler) error {
+		if err := fc.expr(x.Body); err != nil {
+			return err
+		}
+		fc.emit(RETURN_VALUE, 0)
+		return nil
+	})
+}
+
+// push the function of a def or a lambda, body compile the code of the
+// function once its parameters are in place
+func (c *compiler) function(node parser.Node, name string, params *parser.Params, body func(fc *compiler) error) error {
+	symbols := c.symbols.Function(node)
+	fc := newCompiler(name, symbols)
+	fc.code.Params = params.Params
+	fc.pos = node.Pos()
+	// parameters captured by nested functions are moved into their cell
+	for i, param := range symbols.Params() {
+		if symbols.IsCell(param) {
+			fc.emit(LOAD_FAST, i)
+			fc.emit(STORE_DEREF, fc.cell(param))
+		}
+	}
+	if err := body(fc); err != nil {
+		return err
+	}
+
+	// the defaults are evaluated when the def run, in the enclosing scope
+	defaults, kwDefaults := 0, 0
+	for _, x := range params.Defaults {
+		if err := c.expr(x); err != nil {
+			return err
+		}
+		defaults++
+	}
+	if defaults > 0 {
+		c.emit(BUILD_TUPLE, defaults)
+	}
+	for i, x := range params.KwDefaults {
+		if x == nil {
+			continue
+		}
+		c.emit(LOAD_CONST, c.constant(object.Str(params.Names[params.Argcount+i])))
+		if err := c.expr(x); err != nil {
+			return err
+		}
+		kwDefaults++
+	}
+	if kwDefaults > 0 {
+		c.emit(BUILD_MAP, kwDefaults)
+	}
+
+	c.pos = node.Pos()
+	for _, name := range symbols.Freevars() {
+		c.emit(LOAD_CLOSURE, c.cell(name))
+	}
+	c.emit(LOAD_CONST, c.constant(fc.code))
+	c.emit(MAKE_FUNCTION, len(symbols.Freevars()))
+	if kwDefaults > 0 {
+		c.emit(SET_DEFAULTS, 2)
+	}
+	if defaults > 0 {
+		c.emit(SET_DEFAULTS, 1)
+	}
+	return nil
+}
+
+// pop the value on top of the stack into an assignment target
+func (c *compiler) store(target parser.Expr) error {
+	switch t := target.(type) {
+	case *parser.Name:
+		c.pos = t.Token
+		c.storeName(t.Name)
+	case *parser.Subscript:
+		if err := c.expr(t.X); err != nil {
+			return err
+		}
+		if err := c.expr(t.Index); err != nil {
+			return err
+		}
+		c.pos = t.Token
+		c.emit(STORE_SUBSCR, 0)
+	case *parser.Attribute:
+		if err := c.expr(t.X); err != nil {
+			return err
+		}
+		c.pos = t.Token
+		c.emit(STORE_ATTR, c.name(t.Name))
+	case *parser.TupleLit:
+		return c.storeList(t.Token, t.Elts)
+	case *parser.ListLit:
+		return c.storeList(t.Token, t.Elts)
+	default:
+		return c.errorf("cannot assign to expression")
+	}
+	return nil
+}
+
+// pop an iterable and assign its items to a list of targets, a starred
+// target get a list of the items the others leave
+func (c *compiler) storeList(pos tokenizer.Token, elts []parser.Expr) error {
+	starred := -1
+	for i, elt := range elts {
+		if _, ok := elt.(*parser.Starred); ok {
+			starred = i
+		}
+	}
+	c.pos = pos
+	if starred < 0 {
+		c.emit(UNPACK_SEQUENCE, len(elts))
+	} else {
+		after := len(elts) - starred - 1
+		if starred > 0xff || after > 0xffff {
+			return c.errorf("too many expressions in star-unpacking assignment")
+		}
+		c.emit(UNPACK_EX, starred|after<<8)
+	}
+	for _, elt := range elts {
+		if s, ok := elt.(*parser.Starred); ok {
+			elt = s.X
+		}
+		if err := c.store(elt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unbind a name, or delete an item or an attribute
+func (c *compiler) delete(target parser.Expr) error {
+	switch t := target.(type) {
+	case *parser.Name:
+		c.pos = t.Token
+		c.deleteName(t.Name)
+	case *parser.Subscript:
+		if err := c.expr(t.X); err != nil {
+			return err
+		}
+		if err := c.expr(t.Index); err != nil {
+			return err
+		}
+		c.pos = t.Token
+		c.emit(DELETE_SUBSCR, 0)
+	case *parser.Attribute:
+		if err := c.expr(t.X); err != nil {
+			return err
+		}
+		c.pos = t.Token
+		c.emit(DELETE_ATTR, c.name(t.Name))
+	case *parser.TupleLit:
+		return c.deleteList(t.Elts)
+	case *parser.ListLit:
+		return c.deleteList(t.Elts)
+	default:
+		return c.errorf("cannot delete expression")
+	}
+	return nil
+}
+
+func (c *compiler) deleteList(elts []parser.Expr) error {
+	for _, elt := range elts {
+		if err := c.delete(elt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*##################
+### EXPRESSIONS ###
+##################*/
+
+func (c *compiler) expr(expr parser.Expr) error {
+	switch x := expr.(type) {
+	case *parser.IntLit:
+		c.pos = x.Token
+		if x.Big != nil {
+			c.emit(LOAD_CONST, c.constant(object.NewInt(x.Big)))
+		} else {
+			c.emit(LOAD_CONST, c.constant(object.Int(x.Value)))
+		}
+	case *parser.FloatLit:
+		c.pos = x.Token
+		c.emit(LOAD_CONST, c.constant(object.Float(x.Value)))
+	case *parser.StrLit:
+		c.pos = x.Token
+		c.emit(LOAD_CONST, c.constant(object.Str(x.Value)))
+	case *parser.BoolLit:
+		c.pos = x.Token
+		c.emit(LOAD_CONST, c.constant(object.Bool(x.Value)))
+	case *parser.NoneLit:
+		c.pos = x.Token
+		c.emit(LOAD_CONST, c.constant(object.None))
+	case *parser.Name:
+		c.pos = x.Token
+		c.loadName(x.Name)
+	case *parser.UnaryOp:
+		if err := c.expr(x.X); err != nil {
+			return err
+		}
+		c.pos = x.Token
+		c.emit(UNARY_OP, x.Op)
+	case *parser.BoolOp:
+		// the left operand stay as the value when it decide
+		if err := c.expr(x.Left); err != nil {
+			return err
+		}
+		c.pos = x.Token
+		op := JUMP_IF_FALSE_OR_POP
+		if x.Op == tokenizer.OR {
+			op = JUMP_IF_TRUE_OR_POP
+		}
+		jumpEnd := c.emit(op, 0)
+		if err := c.expr(x.Right); err != nil {
+			return err
+		}
+		c.patch(jumpEnd, len(c.code.Instrs))
+	case *parser.IfExp:
+		if err := c.expr(x.Cond); err != nil {
+			return err
+		}
+		base := c.depth - 1
+		jumpElse := c.emit(POP_JUMP_IF_FALSE, 0)
+		if err := c.expr(x.Body); err != nil {
+			return err
+		}
+		jumpEnd := c.emit(JUMP, 0)
+		c.patch(jumpElse, len(c.code.Instrs))
+		c.setDepth(base)
+		if err := c.expr(x.Else); err != nil {
+			return err
+		}
+		c.patch(jumpEnd, len(c.code.Instrs))
+	case *parser.BinaryOp:
+		if err := c.expr(x.Left); err != nil {
+			return err
+		}
+		if err := c.expr(x.Right); err != nil {
+			return err
+		}
+		c.pos = x.Token
+		c.emit(BINARY_OP, x.Op)
+	case *parser.Compare:
+		return c.compare(x)
+	case *parser.ListLit:
+		return c.display(x.Token, x.Elts, BUILD_LIST)
+	case *parser.TupleLit:
+		return c.display(x.Token, x.Elts, BUILD_TUPLE)
+	case *parser.SetLit:
+		return c.display(x.Token, x.Elts, BUILD_SET)
+	case *parser.Starred:
+		c.pos = x.Token
+		return c.errorf("can't use starred expression here")
+	case *parser.DictLit:
+		for i := range x.Keys {
+			if err := c.expr(x.Keys[i]); err != nil {
+				return err
+			}
+			if err := c.expr(x.Values[i]); err != nil {
+				return err
+			}
+		}
+		c.pos = x.Token
+		c.emit(BUILD_MAP, len(x.Keys))
+	case *parser.Subscript:
+		if err := c.expr(x.X); err != nil {
+			return err
+		}
+		if err := c.expr(x.Index); err != nil {
+			return err
+		}
+		c.pos = x.Token
+		c.emit(BINARY_SUBSCR, 0)
+	case *parser.Slice:
+		bounds := []parser.Expr{x.Lower, x.Upper}
+		if x.Step != nil {
+			bounds = append(bounds, x.Step)
+		}
+		for _, bound := range bounds {
+			if bound == nil {
+				c.emit(LOAD_CONST, c.constant(object.None))
+			} else if err := c.expr(bound); err != nil {
+				return err
+			}
+		}
+		c.pos = x.Token
+		c.emit(BUILD_SLICE, len(bounds))
+	case *parser.Attribute:
+		if err := c.expr(x.X); err != nil {
+			return err
+		}
+		c.pos = x.Token
+		c.emit(LOAD_ATTR, c.name(x.Name))
+	case *parser.Call:
+		return c.call(x)
+	case *parser.Lambda:
+		return c.lambda(x)
+	default:
+		return c.errorf("unknown expression %T", expr)
+	}
+	return nil
+}
+
+// a comparison chain, each middle operand is kept under the result of the
+// comparison before it, for the next one:
+//
+//	    <left>
+//	    <comparator>              for each comparison but the last
+//	    DUP_TOP
+//	    ROT_THREE
+//	    COMPARE_OP op
+//	    JUMP_IF_FALSE_OR_POP cleanup
+//	    <last comparator>
+//	    COMPARE_OP last op
+//	    JUMP end
+//	cleanup:                      false result over the kept operand
+//	    ROT_TWO
+//	    POP_TOP
+//	end:
+func (c *compiler) compare(x *parser.Compare) error {
+	if err := c.expr(x.Left); err != nil {
+		return err
+	}
+	base := c.depth - 1
+	var cleanups []int
+	last := len(x.Ops) - 1
+	for i, op := range x.Ops {
+		if err := c.expr(x.Comparators[i]); err != nil {
+			return err
+		}
+		c.pos = x.Token
+		if i < last {
+			c.emit(DUP_TOP, 0)
+			c.emit(ROT_THREE, 0)
+		}
+		c.emit(COMPARE_OP, op)
+		if i < last {
+			cleanups = append(cleanups, c.emit(JUMP_IF_FALSE_OR_POP, 0))
+		}
+	}
+	if len(cleanups) == 0 {
+		return nil
+	}
+	end := c.emit(JUMP, 0)
+	for _, at := range cleanups {
+		c.patch(at, len(c.code.Instrs))
+	}
+	c.setDepth(base + 2)
+	c.emit(ROT_TWO, 0)
+	c.emit(POP_TOP, 0)
+	c.patch(end, len(c.code.Instrs))
+	return nil
+}
+
+// a call with plain arguments is a CALL, or a CALL_KW when some are keyword
+// arguments. With *args or **kwargs the positional arguments are put in a
+// tuple and the keyword ones in a dict for CALL_FUNCTION_EX
+func (c *compiler) call(x *parser.Call) error {
+	if err := c.expr(x.Func); err != nil {
+		return err
+	}
+	unpacking := false
+	for _, arg := range x.Args {
+		if _, ok := arg.(*parser.Starred); ok {
+			unpacking = true
+		}
+	}
+	for _, kw := range x.Keywords {
+		if kw.Name == "" {
+			unpacking = true
+		}
+	}
+	if unpacking {
+		return c.callEx(x)
+	}
+
+	for _, arg := range x.Args {
+		if err := c.expr(arg); err != nil {
+			return err
+		}
+	}
+	if len(x.Keywords) == 0 {
+		c.pos = x.Token
+		c.emit(CALL, len(x.Args))
+		return nil
+	}
+	names := make([]object.Value, len(x.Keywords))
+	for i, kw := range x.Keywords {
+		if err := c.expr(kw.Value); err != nil {
+			return err
+		}
+		names[i] = object.Str(kw.Name)
+	}
+	c.emit(LOAD_CONST, c.constant(&object.Tuple{Items: names}))
+	c.pos = x.Token
+	c.emit(CALL_KW, len(x.Args)+len(x.Keywords))
+	return nil
+}
+
+// the arguments of a call with *args or **kwargs, the function is already
+// on the stack. The keyword arguments before the first "**" one make the
+// dict, the next ones are merged into it with DICT_MERGE
+func (c *compiler) callEx(x *parser.Call) error {
+	var only *parser.Starred
+	if len(x.Args) == 1 {
+		only, _ = x.Args[0].(*parser.Starred)
+	}
+	if only != nil {
+		// CALL_FUNCTION_EX turn the value into a tuple
+		if err := c.expr(only.X); err != nil {
+			return err
+		}
+	} else if err := c.display(x.Token, x.Args, BUILD_TUPLE); err != nil {
+		return err
+	}
+	if len(x.Keywords) == 0 {
+		c.pos = x.Token
+		c.emit(CALL_FUNCTION_EX, 0)
+		return nil
+	}
+
+	// plain keyword arguments are added by groups, a group made after the
+	// dict is merged like a "**" argument so repeated names are found
+	group := 0
+	made := false
+	endGroup := func() {
+		if group > 0 || !made {
+			c.pos = x.Token
+			c.emit(BUILD_MAP, group)
+			if made {
+				c.emit(DICT_MERGE, 2)
+			}
+			made, group = true, 0
+		}
+	}
+	for _, kw := range x.Keywords {
+		if kw.Name != "" {
+			c.emit(LOAD_CONST, c.constant(object.Str(kw.Name)))
+			if err := c.expr(kw.Value); err != nil {
+				return err
+			}
+			group++
+			continue
+		}
+		endGroup()
+		if err := c.expr(kw.Value); err != nil {
+			return err
+		}
+		c.pos = kw.Token
+		c.emit(DICT_MERGE, 2)
+	}
+	endGroup()
+	c.pos = x.Token
+	c.emit(CALL_FUNCTION_EX, 1)
+	return nil
+}
+
+// build a tuple, a list or a set from its items. With a starred item the
+// items are added one at a time to a list or a set
+func (c *compiler) display(pos tokenizer.Token, elts []parser.Expr, build Opcode) error {
+	starred := false
+	for _, elt := range elts {
+		if _, ok := elt.(*parser.Starred); ok {
+			starred = true
+		}
+	}
+	if !starred {
+		for _, elt := range elts {
+			if err := c.expr(elt); err != nil {
+				return err
+			}
+		}
+		c.pos = pos
+		c.emit(build, len(elts))
+		return nil
+	}
+
+	c.pos = pos
+	add, extend := LIST_APPEND, LIST_EXTEND
+	if build == BUILD_SET {
+		add, extend = SET_ADD, SET_UPDATE
+		c.emit(BUILD_SET, 0)
+	} else {
+		c.emit(BUILD_LIST, 0)
+	}
+	for _, elt := range elts {
+		if s, ok := elt.(*parser.Starred); ok {
+			if err := c.expr(s.X); err != nil {
+				return err
+			}
+			c.pos = s.Token
+			c.emit(extend, 0)
+			continue
+		}
+		if err := c.expr(elt); err != nil {
+			return err
+		}
+		c.pos = pos
+		c.emit(add, 0)
+	}
+	if build == BUILD_TUPLE {
+		c.emit(LIST_TO_TUPLE, 0)
+	}
+	return nil
+}
@@ -0,0 +1,981 @@
+// Tree walking evaluator for the AST built by the parser
+package evaluator
+
+import (
+	"fmt"
+	"io"
+	"test1/object"
+	"test1/parser"
+	"test1/symtable"
+	"test1/tokenizer"
+)
+
+// local names of one function call, module level names live in symtab.
+// Where each name is bound is found by the symtable package before the
+// module runs, like for the bytecode
+type scope struct {
+	name    string // function name, for tracebacks
+	symbols *symtable.Symbols
+	names   map[string]object.Value // locals not captured by nested functions
+	cells   map[string]*cell        // captured locals and free variables
+}
+
+// variable shared between a function and the functions nested in it, value
+// is nil until the variable is assigned
+type cell struct {
+	value object.Value
+}
+
+// function object created by a def statement or a lambda
+type function struct {
+	def        *parser.FuncDef
+	symbols    *symtable.Symbols
+	defaults   []object.Value          // defaults of the last positional parameters
+	kwDefaults map[string]object.Value // defaults of the keyword-only parameters
+	closure    map[string]*cell        // cells of the free variables
+	e          *Evaluator              // evaluator running the function when a builtin call it
+}
+
+func (f *function) Type() *object.Type { return object.FunctionType }
+func (f *function) Repr() string       { return fmt.Sprintf("<function %s at %p>", f.def.Name, f) }
+
+// call from a builtin, ex: the key function of min()
+func (f *function) Call(args []object.Value, kwargs map[string]object.Value) (object.Value, error) {
+	args, kwnames := object.SortedKeywords(args, kwargs)
+	return f.e.callFunction(f, args, kwnames)
+}
+func (f *function) Truth() bool      { return true }
+func (f *function) FuncName() string { return f.def.Name }
+
+// tell the statement loop to keep going or to unwind a return, a break or
+// a continue
+type control int
+
+const (
+	next control = iota
+	returning
+	breaking
+	continuing
+)
+
+type Evaluator struct {
+	symtab      map[string]object.Value // global names and their values
+	symbols     *symtable.Symbols       // names of the running module
+	Builtins    map[string]object.Value // names found when they are not global
+	frames      []*scope                // frame stack, one scope per active function call
+	returnValue object.Value            // value of the last return statement
+	handling    []*object.Exception     // exceptions of the running except clauses, innermost last
+	interactive bool                    // echo module level expression statements, for the REPL
+	object.Streams
+}
+
+func New() *Evaluator {
+	e := &Evaluator{symtab: make(map[string]object.Value), Streams: object.StandardStreams()}
+	e.Builtins = object.NewBuiltins(&e.Streams)
+	return e
+}
+
+// deepest frame nesting before RecursionError, the module is the first frame
+const maxDepth = 1000
+
+// run every statement of the module, an uncaught exception is returned as
+// an *object.Exception
+func (e *Evaluator) Run(m *parser.Module) error {
+	symbols, err := symtable.Resolve(m)
+	if err != nil {
+		return err
+	}
+	e.symbols = symbols
+	_, err = e.execBlock(m.Body)
+	return err
+}
+
+// run statements typed at the REPL, the repr of the value of module level
+// expression statements is printed unless it is None
+func (e *Evaluator) RunInteractive(m *parser.Module) error {
+	e.interactive = true
+	defer func() { e.interactive = false }()
+	return e.Run(m)
+}
+
+// add the position of node n in the running function to the traceback of err
+func (e *Evaluator) raise(err error, n parser.Node) error {
+	exc := object.AsException(err)
+	name := "<module>"
+	if s := e.currentScope(); s != nil {
+		name = s.name
+	}
+	pos := n.Pos()
+	exc.AddTraceback(name, pos.Row, pos.Column)
+	exc.SetContext(e.handledException())
+	return exc
+}
+
+// exception of the innermost running except clause, nil outside of them
+func (e *Evaluator) handledException() *object.Exception {
+	if len(e.handling) == 0 {
+		return nil
+	}
+	return e.handling[len(e.handling)-1]
+}
+
+// raise a new exception of class cls at node n
+func (e *Evaluator) errorf(n parser.Node, cls *object.Type, format string, args ...interface{}) error {
+	return e.raise(object.Errorf(cls, format, args...), n)
+}
+
+// SyntaxError found while running, ex: return outside a function
+func syntaxError(n parser.Node, format string, args ...interface{}) error {
+	exc := object.Errorf(object.SyntaxError, format, args...)
+	exc.Row, exc.Column = n.Pos().Row, n.Pos().Column
+	return exc
+}
+
+/*##################
+### NAME LOOKUP ###
+##################*/
+
+// return the scope of the running function, nil at module level
+func (e *Evaluator) currentScope() *scope {
+	if len(e.frames) == 0 {
+		return nil
+	}
+	return e.frames[len(e.frames)-1]
+}
+
+// symbols of the running function, or of the module
+func (e *Evaluator) currentSymbols() *symtable.Symbols {
+	if s := e.currentScope(); s != nil {
+		return s.symbols
+	}
+	return e.symbols
+}
+
+// how name is found in the running function, module level names are all
+// global
+func (e *Evaluator) scopeOf(name string) (*scope, symtable.Scope) {
+	s := e.currentScope()
+	if s == nil {
+		return nil, symtable.ScopeName
+	}
+	return s, s.symbols.Scope(name)
+}
+
+// value of a local, of a cell, or of a global then a builtin
+func (e *Evaluator) lookup(name string) (object.Value, error) {
+	s, scope := e.scopeOf(name)
+	switch scope {
+	case symtable.ScopeLocal:
+		if v, ok := s.names[name]; ok {
+			return v, nil
+		}
+		return nil, object.Errorf(object.UnboundLocalError, "local variable '%s' referenced before assignment", name)
+	case symtable.ScopeCell:
+		if v := s.cells[name].value; v != nil {
+			return v, nil
+		}
+		return nil, s.unboundCell(name)
+	}
+	if v, ok := e.symtab[name]; ok {
+		return v, nil
+	}
+	if v, ok := e.Builtins[name]; ok {
+		return v, nil
+	}
+	return nil, object.Errorf(object.NameError, "name '%s' is not defined", name)
+}
+
+// error for an empty cell, a captured local is unbound like the other locals
+func (s *scope) unboundCell(name string) *object.Exception {
+	for _, free := range s.symbols.Freevars() {
+		if free == name {
+			return object.Errorf(object.NameError, "free variable '%s' referenced before assignment in enclosing scope", name)
+		}
+	}
+	return object.Errorf(object.UnboundLocalError, "local variable '%s' referenced before assignment", name)
+}
+
+// bind name where the symtable put it
+func (e *Evaluator) assign(name string, v object.Value) {
+	s, scope := e.scopeOf(name)
+	switch scope {
+	case symtable.ScopeLocal:
+		s.names[name] = v
+	case symtable.ScopeCell:
+		s.cells[name].value = v
+	default:
+		e.symtab[name] = v
+	}
+}
+
+/*#################
+### STATEMENTS ###
+#################*/
+
+func (e *Evaluator) execBlock(body []parser.Stmt) (control, error) {
+	for _, s := range body {
+		ctrl, err := e.exec(s)
+		if err != nil || ctrl != next {
+			return ctrl, err
+		}
+	}
+	return next, nil
+}
+
+func (e *Evaluator) exec(stmt parser.Stmt) (control, error) {
+	switch s := stmt.(type) {
+	case *parser.Assign:
+		v, err := e.eval(s.Value)
+		if err != nil {
+			return next, err
+		}
+		for _, target := range s.Targets {
+			if err := e.store(target, v); err != nil {
+				return next, err
+			}
+		}
+	case *parser.Delete:
+		for _, target := range s.Targets {
+			if err := e.delete(target); err != nil {
+				return next, err
+			}
+		}
+	case *parser.ExprStmt:
+		v, err := e.eval(s.X)
+		if err == nil && e.interactive && e.currentScope() == nil && v != object.None {
+			io.WriteString(e.Stdout, v.Repr()+"\n")
+		}
+		return next, err
+	case *parser.Import:
+		importFn := e.Builtins["__import__"]
+		for _, alias := range s.Names {
+			module, err := e.callFunction(importFn, []object.Value{object.Str(alias.Name)}, nil)
+			if err != nil {
+				return next, e.raise(err, s)
+			}
+			e.assign(alias.AsName, module)
+		}
+	case *parser.Pass:
+	case *parser.Return:
+		var v object.Value = object.None
+		if s.Value != nil {
+			var err error
+			if v, err = e.eval(s.Value); err != nil {
+				return next, err
+			}
+		}
+		e.returnValue = v
+		return returning, nil
+	case *parser.Global, *parser.Nonlocal:
+		// declarations are handled by the symtable
+	case *parser.If:
+		v, err := e.eval(s.Cond)
+		if err != nil {
+			return next, err
+		}
+		if v.Truth() {
+			return e.execBlock(s.Body)
+		} else if s.Else != nil {
+			return e.execBlock(s.Else)
+		}
+	case *parser.While:
+		for {
+			v, err := e.eval(s.Cond)
+			if err != nil {
+				return next, err
+			}
+			if !v.Truth() {
+				return e.execBlock(s.Else)
+			}
+			ctrl, err := e.execBlock(s.Body)
+			if ctrl, done := loopControl(ctrl, err); done {
+				return ctrl, err
+			}
+		}
+	case *parser.For:
+		return e.forstmt(s)
+	case *parser.Break:
+		return breaking, nil
+	case *parser.Continue:
+		return continuing, nil
+	case *parser.FuncDef:
+		fn, err := e.funcdef(s, s)
+		if err != nil {
+			return next, err
+		}
+		e.assign(s.Name, fn)
+	case *parser.Try:
+		return e.trystmt(s)
+	case *parser.Raise:
+		return next, e.raisestmt(s)
+	default:
+		return next, e.errorf(stmt, object.SystemError, "unknown statement %T", stmt)
+	}
+	return next, nil
+}
+
+// the function of a def, or of the lambda node, its defaults are evaluated
+// now in the current scope and its free variables get the cells of the
+// current scope
+func (e *Evaluator) funcdef(s *parser.FuncDef, node parser.Node) (*function, error) {
+	fn := &function{def: s, symbols: e.currentSymbols().Function(node), e: e}
+	if free := fn.symbols.Freevars(); len(free) > 0 {
+		fn.closure = make(map[string]*cell, len(free))
+		for _, name := range free {
+			fn.closure[name] = e.currentScope().cells[name]
+		}
+	}
+	for _, x := range s.Params.Defaults {
+		v, err := e.eval(x)
+		if err != nil {
+			return nil, err
+		}
+		fn.defaults = append(fn.defaults, v)
+	}
+	for i, x := range s.Params.KwDefaults {
+		if x == nil {
+			continue
+		}
+		v, err := e.eval(x)
+		if err != nil {
+			return nil, err
+		}
+		if fn.kwDefaults == nil {
+			fn.kwDefaults = map[string]object.Value{}
+		}
+		fn.kwDefaults[s.Params.Names[s.Params.Argcount+i]] = v
+	}
+	return fn, nil
+}
+
+// assign v to a name, an item, an attribute or a list of targets
+// what a loop does after its body ran: done is true when it must stop and
+// return ctrl, a break end the loop without running its else block
+func loopControl(ctrl control, err error) (control, bool) {
+	switch {
+	case err != nil || ctrl == returning:
+		return ctrl, true
+	case ctrl == breaking:
+		return next, true
+	}
+	return next, false
+}
+
+// run the body for each item of the iterable, then the else block unless
+// a break stopped the loop
+func (e *Evaluator) forstmt(s *parser.For) (control, error) {
+	v, err := e.eval(s.Iter)
+	if err != nil {
+		return next, err
+	}
+	it, err := object.Iter(v)
+	if err != nil {
+		return next, e.raise(err, s.Iter)
+	}
+	for {
+		item, ok, err := it.Next()
+		if err != nil {
+			return next, e.raise(err, s)
+		}
+		if !ok {
+			return e.execBlock(s.Else)
+		}
+		if err := e.store(s.Target, item); err != nil {
+			return next, err
+		}
+		ctrl, err := e.execBlock(s.Body)
+		if ctrl, done := loopControl(ctrl, err); done {
+			return ctrl, err
+		}
+	}
+}
+
+func (e *Evaluator) store(target parser.Expr, v object.Value) error {
+	switch t := target.(type) {
+	case *parser.Name:
+		e.assign(t.Name, v)
+	case *parser.Subscript:
+		x, key, err := e.evalSubscript(t)
+		if err != nil {
+			return err
+		}
+		if err := object.SetItem(x, key, v); err != nil {
+			return e.raise(err, t)
+		}
+	case *parser.Attribute:
+		x, err := e.eval(t.X)
+		if err != nil {
+			return err
+		}
+		if err := object.SetAttr(x, t.Name, v); err != nil {
+			return e.raise(err, t)
+		}
+	case *parser.TupleLit:
+		return e.storeList(t, t.Elts, v)
+	case *parser.ListLit:
+		return e.storeList(t, t.Elts, v)
+	default:
+		return syntaxError(target, "cannot assign to expression")
+	}
+	return nil
+}
+
+// assign the items of v to a list of targets, a starred target get a list
+// of the items the others leave
+func (e *Evaluator) storeList(n parser.Node, elts []parser.Expr, v object.Value) error {
+	starred := -1
+	for i, elt := range elts {
+		if _, ok := elt.(*parser.Starred); ok {
+			starred = i
+		}
+	}
+	var items []object.Value
+	var err error
+	if starred < 0 {
+		items, err = object.Unpack(v, len(elts), 0, false)
+	} else {
+		items, err = object.Unpack(v, starred, len(elts)-starred-1, true)
+	}
+	if err != nil {
+		return e.raise(err, n)
+	}
+	for i, elt := range elts {
+		if s, ok := elt.(*parser.Starred); ok {
+			elt = s.X
+		}
+		if err := e.store(elt, items[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unbind a name, or delete an item or an attribute
+func (e *Evaluator) delete(target parser.Expr) error {
+	switch t := target.(type) {
+	case *parser.Name:
+		return e.unbind(t)
+	case *parser.Subscript:
+		x, key, err := e.evalSubscript(t)
+		if err != nil {
+			return err
+		}
+		if err := object.DelItem(x, key); err != nil {
+			return e.raise(err, t)
+		}
+	case *parser.Attribute:
+		x, err := e.eval(t.X)
+		if err != nil {
+			return err
+		}
+		if err := object.SetAttr(x, t.Name, nil); err != nil {
+			return e.raise(err, t)
+		}
+	case *parser.TupleLit:
+		for _, elt := range t.Elts {
+			if err := e.delete(elt); err != nil {
+				return err
+			}
+		}
+	case *parser.ListLit:
+		for _, elt := range t.Elts {
+			if err := e.delete(elt); err != nil {
+				return err
+			}
+		}
+	default:
+		return syntaxError(target, "cannot delete expression")
+	}
+	return nil
+}
+
+// remove a name from the scope it is bound in, like assign find that scope
+func (e *Evaluator) unbind(n *parser.Name) error {
+	s, scope := e.scopeOf(n.Name)
+	switch scope {
+	case symtable.ScopeLocal:
+		if _, ok := s.names[n.Name]; !ok {
+			return e.errorf(n, object.UnboundLocalError, "local variable '%s' referenced before assignment", n.Name)
+		}
+		delete(s.names, n.Name)
+	case symtable.ScopeCell:
+		c := s.cells[n.Name]
+		if c.value == nil {
+			return e.raise(s.unboundCell(n.Name), n)
+		}
+		c.value = nil
+	default:
+		if _, ok := e.symtab[n.Name]; !ok {
+			return e.errorf(n, object.NameError, "name '%s' is not defined", n.Name)
+		}
+		delete(e.symtab, n.Name)
+	}
+	return nil
+}
+
+// run the body, then the first except clause matching the exception or the
+// else block, the finally block always run last
+func (e *Evaluator) trystmt(s *parser.Try) (control, error) {
+	ctrl, err := e.execBlock(s.Body)
+	if err != nil && len(s.Handlers) > 0 {
+		ctrl, err = e.handle(s.Handlers, err.(*object.Exception))
+	} else if err == nil && ctrl == next && s.Else != nil {
+		ctrl, err = e.execBlock(s.Else)
+	}
+	if s.Finally == nil {
+		return ctrl, err
+	}
+
+	// an exception or a return in the finally block replace the pending
+	// one, else the pending one continue after it
+	returnValue := e.returnValue
+	exc, _ := err.(*object.Exception)
+	if exc != nil {
+		e.handling = append(e.handling, exc)
+	}
+	finallyCtrl, finallyErr := e.execBlock(s.Finally)
+	if exc != nil {
+		e.handling = e.handling[:len(e.handling)-1]
+	}
+	if finallyErr != nil || finallyCtrl != next {
+		return finallyCtrl, finallyErr
+	}
+	e.returnValue = returnValue
+	return ctrl, err
+}
+
+// run the first except clause matching exc, exc is raised again when none match
+func (e *Evaluator) handle(handlers []*parser.ExceptHandler, exc *object.Exception) (control, error) {
+	e.handling = append(e.handling, exc)
+	defer func() { e.handling = e.handling[:len(e.handling)-1] }()
+	for _, h := range handlers {
+		if h.Type != nil {
+			cls, err := e.eval(h.Type)
+			if err != nil {
+				return next, err
+			}
+			match, err := exc.Matches(cls)
+			if err != nil {
+				return next, e.raise(err, h.Type)
+			}
+			if !match {
+				continue
+			}
+		}
+		if h.Name == "" {
+			return e.execBlock(h.Body)
+		}
+		e.assign(h.Name, exc)
+		ctrl, err := e.execBlock(h.Body)
+		// like CPython the name is unbound when the clause ends, it is set
+		// to None first so a name the clause deleted does not raise
+		e.assign(h.Name, object.None)
+		e.unbind(&parser.Name{Token: h.Token, Name: h.Name})
+		return ctrl, err
+	}
+	return next, exc
+}
+
+// raise the exception, or raise again the one being handled for a bare raise
+func (e *Evaluator) raisestmt(s *parser.Raise) error {
+	if s.Exc == nil {
+		if exc := e.handledException(); exc != nil {
+			return exc
+		}
+		return e.errorf(s, object.RuntimeError, "No active exception to reraise")
+	}
+	v, err := e.eval(s.Exc)
+	if err != nil {
+		return err
+	}
+	exc, err := object.NewException(v)
+	if err != nil {
+		return e.raise(err, s)
+	}
+	if s.Cause != nil {
+		cause, err := e.eval(s.Cause)
+		if err != nil {
+			return err
+		}
+		if err := exc.SetCause(cause); err != nil {
+			return e.raise(err, s)
+		}
+	}
+	return e.raise(exc, s)
+}
+
+/*##################
+### EXPRESSIONS ###
+##################*/
+
+func (e *Evaluator) eval(expr parser.Expr) (object.Value, error) {
+	switch x := expr.(type) {
+	case *parser.IntLit:
+		if x.Big != nil {
+			return object.NewInt(x.Big), nil
+		}
+		return object.Int(x.Value), nil
+	case *parser.FloatLit:
+		return object.Float(x.Value), nil
+	case *parser.StrLit:
+		return object.Str(x.Value), nil
+	case *parser.BoolLit:
+		return object.Bool(x.Value), nil
+	case *parser.NoneLit:
+		return object.None, nil
+	case *parser.Name:
+		v, err := e.lookup(x.Name)
+		if err != nil {
+			return nil, e.raise(err, x)
+		}
+		return v, nil
+	case *parser.UnaryOp:
+		v, err := e.eval(x.X)
+		if err != nil {
+			return nil, err
+		}
+		v, err = object.UnaryOp(x.Op, v)
+		if err != nil {
+			return nil, e.raise(err, x)
+		}
+		return v, nil
+	case *parser.BoolOp:
+		// the left operand is the value when it decide
+		left, err := e.eval(x.Left)
+		if err != nil || left.Truth() == (x.Op == tokenizer.OR) {
+			return left, err
+		}
+		return e.eval(x.Right)
+	case *parser.IfExp:
+		cond, err := e.eval(x.Cond)
+		if err != nil {
+			return nil, err
+		}
+		if cond.Truth() {
+			return e.eval(x.Body)
+		}
+		return e.eval(x.Else)
+	case *parser.BinaryOp:
+		left, err := e.eval(x.Left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := e.eval(x.Right)
+		if err != nil {
+			return nil, err
+		}
+		v, err := object.BinaryOp(x.Op, left, right)
+		if err != nil {
+			return nil, e.raise(err, x)
+		}
+		return v, nil
+	case *parser.Compare:
+		return e.compare(x)
+	case *parser.Call:
+		return e.call(x)
+	case *parser.Lambda:
+		// a def named <lambda> whose body return the value of the lambda body
+		def := &parser.FuncDef{Token: x.Token, Name: "<lambda>", Params: x.Params,
+			Body: []parser.Stmt{&parser.Return{Token: x.Token, Value: x.Body}}}
+		fn, err := e.funcdef(def, x)
+		if err != nil {
+			return nil, err
+		}
+		return fn, nil
+	case *parser.ListLit:
+		items, err := e.evalItems(x.Elts)
+		if err != nil {
+			return nil, err
+		}
+		return &object.List{Items: items}, nil
+	case *parser.TupleLit:
+		items, err := e.evalItems(x.Elts)
+		if err != nil {
+			return nil, err
+		}
+		return &object.Tuple{Items: items}, nil
+	case *parser.SetLit:
+		return e.evalSet(x)
+	case *parser.Starred:
+		return nil, syntaxError(x, "can't use starred expression here")
+	case *parser.DictLit:
+		d := &object.Dict{}
+		for i := range x.Keys {
+			k, err := e.eval(x.Keys[i])
+			if err != nil {
+				return nil, err
+			}
+			v, err := e.eval(x.Values[i])
+			if err != nil {
+				return nil, err
+			}
+			if err := d.Set(k, v); err != nil {
+				return nil, e.raise(err, x)
+			}
+		}
+		return d, nil
+	case *parser.Subscript:
+		v, key, err := e.evalSubscript(x)
+		if err != nil {
+			return nil, err
+		}
+		if v, err = object.GetItem(v, key); err != nil {
+			return nil, e.raise(err, x)
+		}
+		return v, nil
+	case *parser.Slice:
+		s := &object.Slice{Start: object.None, Stop: object.None, Step: object.None}
+		for _, b := range []struct {
+			x   parser.Expr
+			dst *object.Value
+		}{{x.Lower, &s.Start}, {x.Upper, &s.Stop}, {x.Step, &s.Step}} {
+			if b.x == nil {
+				continue
+			}
+			v, err := e.eval(b.x)
+			if err != nil {
+				return nil, err
+			}
+			*b.dst = v
+		}
+		return s, nil
+	case *parser.Attribute:
+		v, err := e.eval(x.X)
+		if err != nil {
+			return nil, err
+		}
+		if v, err = object.GetAttr(v, x.Name); err != nil {
+			return nil, e.raise(err, x)
+		}
+		return v, nil
+	}
+	return nil, e.errorf(expr, object.SystemError, "unknown expression %T", expr)
+}
+
+// the container and the key of x[key]
+// the values of the items of a list or tuple display, a starred item add
+// the items of its value
+func (e *Evaluator) evalItems(elts []parser.Expr) ([]object.Value, error) {
+	items := make([]object.Value, 0, len(elts))
+	for _, elt := range elts {
+		if s, ok := elt.(*parser.Starred); ok {
+			v, err := e.eval(s.X)
+			if err != nil {
+				return nil, err
+			}
+			more, err := object.StarredItems(v)
+			if err != nil {
+				return nil, e.raise(err, s)
+			}
+			items = append(items, more...)
+			continue
+		}
+		v, err := e.eval(elt)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, v)
+	}
+	return items, nil
+}
+
+func (e *Evaluator) evalSet(x *parser.SetLit) (object.Value, error) {
+	set := &object.Set{}
+	for _, elt := range x.Elts {
+		if s, ok := elt.(*parser.Starred); ok {
+			v, err := e.eval(s.X)
+			if err != nil {
+				return nil, err
+			}
+			if err := set.UpdateStarred(v); err != nil {
+				return nil, e.raise(err, s)
+			}
+			continue
+		}
+		v, err := e.eval(elt)
+		if err != nil {
+			return nil, err
+		}
+		if err := set.Add(v); err != nil {
+			return nil, e.raise(err, x)
+		}
+	}
+	return set, nil
+}
+
+func (e *Evaluator) evalSubscript(x *parser.Subscript) (object.Value, object.Value, error) {
+	v, err := e.eval(x.X)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := e.eval(x.Index)
+	if err != nil {
+		return nil, nil, err
+	}
+	return v, key, nil
+}
+
+// a comparison chain stop at the first false comparison, each operand is
+// evaluated once
+func (e *Evaluator) compare(x *parser.Compare) (object.Value, error) {
+	left, err := e.eval(x.Left)
+	if err != nil {
+		return nil, err
+	}
+	var v object.Value
+	for i, op := range x.Ops {
+		right, err := e.eval(x.Comparators[i])
+		if err != nil {
+			return nil, err
+		}
+		if v, err = object.Compare(op, left, right); err != nil {
+			return nil, e.raise(err, x)
+		}
+		if !v.Truth() {
+			break
+		}
+		left = right
+	}
+	return v, nil
+}
+
+// evaluate the function and the arguments then call it, errors raised by the
+// call get the position of the call in the traceback
+func (e *Evaluator) call(x *parser.Call) (object.Value, error) {
+	v, err := e.eval(x.Func)
+	if err != nil {
+		return nil, err
+	}
+	var args []object.Value
+	for _, arg := range x.Args {
+		s, starred := arg.(*parser.Starred)
+		if !starred {
+			v, err := e.eval(arg)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, v)
+			continue
+		}
+		seq, err := e.eval(s.X)
+		if err != nil {
+			return nil, err
+		}
+		var items []object.Value
+		if len(x.Args) == 1 {
+			items, err = object.StarArgs(v, seq)
+		} else {
+			items, err = object.StarredItems(seq)
+		}
+		if err != nil {
+			return nil, e.raise(err, x)
+		}
+		args = append(args, items...)
+	}
+	args, kwnames, err := e.keywords(v, x, args)
+	if err != nil {
+		return nil, err
+	}
+	result, err := e.callFunction(v, args, kwnames)
+	if err != nil {
+		return nil, e.raise(err, x)
+	}
+	return result, nil
+}
+
+// append the values of the keyword arguments of a call to fn to args and
+// return their names. With "**" arguments the keyword arguments are put in a
+// dict first, so repeated names are found
+func (e *Evaluator) keywords(fn object.Value, x *parser.Call, args []object.Value) ([]object.Value, []string, error) {
+	unpacking := false
+	for _, kw := range x.Keywords {
+		if kw.Name == "" {
+			unpacking = true
+		}
+	}
+	var kwnames []string
+	kwargs := &object.Dict{}
+	for _, kw := range x.Keywords {
+		v, err := e.eval(kw.Value)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !unpacking {
+			args = append(args, v)
+			kwnames = append(kwnames, kw.Name)
+			continue
+		}
+		if kw.Name != "" {
+			d := &object.Dict{}
+			d.Set(object.Str(kw.Name), v)
+			v = d
+		}
+		if err := object.MergeKwargs(fn, kwargs, v); err != nil {
+			return nil, nil, e.raise(err, x)
+		}
+	}
+	if !unpacking {
+		return args, kwnames, nil
+	}
+	kwnames, values, err := object.KeywordArgs(kwargs)
+	if err != nil {
+		return nil, nil, e.raise(err, x)
+	}
+	return append(args, values...), kwnames, nil
+}
+
+// bind the arguments in a new scope and run the function body
+// the last len(kwnames) args are the keyword arguments
+func (e *Evaluator) callFunction(v object.Value, args []object.Value, kwnames []string) (object.Value, error) {
+	n := len(args) - len(kwnames)
+	switch f := v.(type) {
+	case *object.Builtin:
+		return f.Call(args[:n], object.KeywordMap(kwnames, args[n:]))
+	case *object.Type:
+		return object.Instantiate(f, args[:n], object.KeywordMap(kwnames, args[n:]))
+	case *object.Method:
+		return f.Call(args[:n], object.KeywordMap(kwnames, args[n:]))
+	}
+	fn, ok := v.(*function)
+	if !ok {
+		return nil, object.Errorf(object.TypeError, "'%s' object is not callable", v.Type().Name)
+	}
+	params := &fn.def.Params.Params
+	args, exc := params.Bind(fn.def.Name, args, kwnames, fn.defaults, fn.kwDefaults)
+	if exc != nil {
+		return nil, exc
+	}
+	if len(e.frames)+1 >= maxDepth {
+		return nil, object.Errorf(object.RecursionError, "maximum recursion depth exceeded")
+	}
+
+	// each call get a new local scope holding the arguments and new cells
+	// for its captured locals
+	s := &scope{name: fn.def.Name, symbols: fn.symbols, names: map[string]object.Value{}}
+	if cellvars := fn.symbols.Cellvars(); len(cellvars)+len(fn.closure) > 0 {
+		s.cells = make(map[string]*cell, len(cellvars)+len(fn.closure))
+		for _, name := range cellvars {
+			s.cells[name] = &cell{}
+		}
+		for name, c := range fn.closure {
+			s.cells[name] = c
+		}
+	}
+	for i, param := range params.Locals() {
+		if c, ok := s.cells[param]; ok {
+			c.value = args[i]
+		} else {
+			s.names[param] = args[i]
+		}
+	}
+	e.frames = append(e.frames, s)
+	ctrl, err := e.execBlock(fn.def.Body)
+	e.frames = e.frames[:len(e.frames)-1]
+	if err != nil {
+		return nil, err
+	}
+	if ctrl == returning {
+		return e.returnValue, nil
+	}
+	return object.None, nil
+}
@@ -0,0 +1,127 @@
+package interp
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"test1/object"
+)
+
+// Python value of a Go value: nil is None, bool, the int and uint types,
+// *big.Int, float32, float64 and string convert to bool, int, float and
+// str, slices and arrays to list and maps to dict. An object.Value is used
+// as it is
+func ToValue(v interface{}) (object.Value, error) {
+	switch x := v.(type) {
+	case nil:
+		return object.None, nil
+	case object.Value:
+		return x, nil
+	case bool:
+		return object.Bool(x), nil
+	case int:
+		return object.Int(x), nil
+	case int8:
+		return object.Int(x), nil
+	case int16:
+		return object.Int(x), nil
+	case int32:
+		return object.Int(x), nil
+	case int64:
+		return object.Int(x), nil
+	case uint8:
+		return object.Int(x), nil
+	case uint16:
+		return object.Int(x), nil
+	case uint32:
+		return object.Int(x), nil
+	case uint:
+		return object.NewInt(new(big.Int).SetUint64(uint64(x))), nil
+	case uint64:
+		return object.NewInt(new(big.Int).SetUint64(x)), nil
+	case *big.Int:
+		return object.NewInt(new(big.Int).Set(x)), nil
+	case float32:
+		return object.Float(x), nil
+	case float64:
+		return object.Float(x), nil
+	case string:
+		return object.Str(x), nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Map {
+		d := &object.Dict{}
+		iter := rv.MapRange()
+		for iter.Next() {
+			key, err := ToValue(iter.Key().Interface())
+			if err != nil {
+				return nil, err
+			}
+			value, err := ToValue(iter.Value().Interface())
+			if err != nil {
+				return nil, err
+			}
+			if err := d.Set(key, value); err != nil {
+				return nil, err
+			}
+		}
+		return d, nil
+	}
+	if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		list := &object.List{Items: make([]object.Value, rv.Len())}
+		for i := range list.Items {
+			item, err := ToValue(rv.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+			list.Items[i] = item
+		}
+		return list, nil
+	}
+	return nil, fmt.Errorf("interp: cannot convert %T to a Python value", v)
+}
+
+// Go value of a Python value: None is nil, bool, int, float, str, list and
+// tuple become bool, int64, float64, string and []interface{}, an int too
+// large for an int64 a *big.Int, a dict with only str keys become a
+// map[string]interface{}. Other values are returned as they are
+func FromValue(v object.Value) interface{} {
+	switch x := v.(type) {
+	case object.NoneValue:
+		return nil
+	case object.Bool:
+		return bool(x)
+	case object.Int:
+		return int64(x)
+	case *object.BigInt:
+		return x.Big()
+	case object.Float:
+		return float64(x)
+	case object.Str:
+		return string(x)
+	case *object.List:
+		return fromValues(x.Items)
+	case *object.Tuple:
+		return fromValues(x.Items)
+	case *object.Dict:
+		m := make(map[string]interface{}, x.Len())
+		for _, key := range x.Keys() {
+			s, ok := key.(object.Str)
+			if !ok {
+				return v
+			}
+			value, _, _ := x.Get(key)
+			m[string(s)] = FromValue(value)
+		}
+		return m
+	}
+	return v
+}
+
+func fromValues(values []object.Value) []interface{} {
+	items := make([]interface{}, len(values))
+	for i, v := range values {
+		items[i] = FromValue(v)
+	}
+	return items
+}
@@ -0,0 +1,134 @@
+// Interpreter that Go programs can embed to run Python source in-process
+package interp
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"test1/compiler"
+	"test1/object"
+	"test1/parser"
+	"test1/tokenizer"
+	"test1/vm"
+)
+
+// name of the source in tracebacks, like python's exec()
+const filename = "<string>"
+
+// one Python interpreter with its own globals. Different interpreters can
+// run at the same time, the calls on one interpreter are run one at a time
+type Interpreter struct {
+	Stdin  io.Reader // read by input()
+	Stdout io.Writer // written by print()
+	Stderr io.Writer // sys.stderr and the traceback of the exceptions Exec does not catch
+
+	mu sync.Mutex
+	vm *vm.VM
+}
+
+// new interpreter using the standard input and outputs of the process
+func New() *Interpreter {
+	return &Interpreter{Stdin: os.Stdin, Stdout: os.Stdout, Stderr: os.Stderr, vm: vm.New()}
+}
+
+// run a module, the names it assign stay in the globals for the next calls.
+// A Python exception is written to Stderr and returned as an
+// *object.Exception. When ctx is done the code is stopped and ctx.Err() is
+// returned
+func (it *Interpreter) Exec(ctx context.Context, src string) error {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	module, err := parser.Parse(tokenizer.NewLexer(src))
+	if err == nil {
+		var code *compiler.Code
+		code, err = compiler.Compile(module)
+		if err == nil {
+			it.vm.Stdin, it.vm.Stdout, it.vm.Stderr = it.Stdin, it.Stdout, it.Stderr
+			it.vm.Done = ctx.Done()
+			err = it.protect(func() error { return it.vm.Run(code) })
+			it.vm.Done = nil
+		}
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		exc := object.AsException(err)
+		io.WriteString(it.Stderr, object.FormatException(exc, filename, strings.Split(src, "\n")))
+		return exc
+	}
+	return nil
+}
+
+// run f, a Go panic while it runs, ex: in a registered function, is
+// returned as a SystemError instead of stopping the host program
+func (it *Interpreter) protect(f func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			it.vm.Reset()
+			err = object.Errorf(object.SystemError, "%v", r)
+		}
+	}()
+	return f()
+}
+
+// value of a single expression using the globals, converted like GetGlobal
+func (it *Interpreter) Eval(expr string) (interface{}, error) {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	x, err := parser.ParseExpr(tokenizer.NewLexer(expr))
+	if err != nil {
+		return nil, err
+	}
+	code, err := compiler.CompileExpr(x)
+	if err != nil {
+		return nil, err
+	}
+	it.vm.Stdin, it.vm.Stdout, it.vm.Stderr = it.Stdin, it.Stdout, it.Stderr
+	var v object.Value
+	err = it.protect(func() (err error) {
+		v, err = it.vm.Eval(code)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return FromValue(v), nil
+}
+
+// bind a global name to a Go value converted with ToValue
+func (it *Interpreter) SetGlobal(name string, value interface{}) error {
+	v, err := ToValue(value)
+	if err != nil {
+		return err
+	}
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	it.vm.SetGlobal(name, v)
+	return nil
+}
+
+// value of a global name converted with FromValue, ok is false when the
+// name is not bound
+func (it *Interpreter) GetGlobal(name string) (value interface{}, ok bool) {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	v, ok := it.vm.Global(name)
+	if !ok {
+		return nil, false
+	}
+	return FromValue(v), true
+}
+
+// make a Go function callable from Python under name, it is found after
+// the globals like the other builtins
+func (it *Interpreter) Register(name string, fn object.BuiltinFunc) {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	it.vm.Builtins[name] = &object.Builtin{Name: name, Fn: fn}
+}
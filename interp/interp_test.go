@@ -0,0 +1,230 @@
+package interp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"sync"
+	"test1/object"
+	"testing"
+	"time"
+)
+
+func newTest() (*Interpreter, *bytes.Buffer, *bytes.Buffer) {
+	it := New()
+	var stdout, stderr bytes.Buffer
+	it.Stdin, it.Stdout, it.Stderr = strings.NewReader(""), &stdout, &stderr
+	return it, &stdout, &stderr
+}
+
+func TestExecCancelInFunction(t *testing.T) {
+	for _, src := range []string{
+		"def f():\n    while True:\n        pass\nf()\n",
+		"def f():\n    x = 0\n    while True:\n        x = x + 1\nf()\n",
+		"while True:\n    pass\n",
+	} {
+		it, _, _ := newTest()
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		err := it.Exec(ctx, src)
+		cancel()
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Exec(%q) = %v, want %v", src, err, context.DeadlineExceeded)
+		}
+		// the interpreter is still usable after the cancellation
+		if err := it.Exec(context.Background(), "y = 1\n"); err != nil {
+			t.Errorf("Exec after cancel: %v", err)
+		}
+	}
+}
+
+func TestExecCanceledBefore(t *testing.T) {
+	it, stdout, _ := newTest()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := it.Exec(ctx, "print('ran')\n"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Exec = %v, want %v", err, context.Canceled)
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("stdout = %q, want nothing", stdout.String())
+	}
+}
+
+func TestExecException(t *testing.T) {
+	it, _, stderr := newTest()
+	err := it.Exec(context.Background(), "x = 1\n1 / 0\n")
+	var exc *object.Exception
+	if !errors.As(err, &exc) || exc.Class != object.ZeroDivisionError {
+		t.Fatalf("Exec = %v, want a ZeroDivisionError", err)
+	}
+	if !strings.Contains(stderr.String(), "ZeroDivisionError: division by zero") {
+		t.Errorf("stderr = %q", stderr.String())
+	}
+	// the globals assigned before the exception are kept
+	if v, ok := it.GetGlobal("x"); !ok || v != int64(1) {
+		t.Errorf("GetGlobal(x) = %v, %v", v, ok)
+	}
+}
+
+func TestParallel(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			it, stdout, _ := newTest()
+			if err := it.SetGlobal("n", i); err != nil {
+				t.Error(err)
+				return
+			}
+			src := "total = 0\nfor k in range(1000):\n    total = total + n\nprint(total)\n"
+			if err := it.Exec(context.Background(), src); err != nil {
+				t.Error(err)
+				return
+			}
+			if want := fmt.Sprintf("%d\n", 1000*i); stdout.String() != want {
+				t.Errorf("interpreter %d printed %q, want %q", i, stdout.String(), want)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestSharedInterpreter(t *testing.T) {
+	it, _, _ := newTest()
+	it.SetGlobal("count", 0)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := it.Exec(context.Background(), "count = count + 1\n"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if v, _ := it.GetGlobal("count"); v != int64(8) {
+		t.Errorf("count = %v, want 8", v)
+	}
+}
+
+func TestGlobals(t *testing.T) {
+	huge, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	tests := []struct {
+		in, want interface{}
+	}{
+		{nil, nil},
+		{true, true},
+		{42, int64(42)},
+		{int8(-3), int64(-3)},
+		{uint64(1) << 63, new(big.Int).Lsh(big.NewInt(1), 63)},
+		{huge, huge},
+		{1.5, 1.5},
+		{float32(0.5), 0.5},
+		{"text", "text"},
+		{[]int{1, 2}, []interface{}{int64(1), int64(2)}},
+		{[2]string{"a", "b"}, []interface{}{"a", "b"}},
+		{map[string]interface{}{"k": []interface{}{"v", nil}}, map[string]interface{}{"k": []interface{}{"v", nil}}},
+	}
+	it, _, _ := newTest()
+	for _, test := range tests {
+		if err := it.SetGlobal("v", test.in); err != nil {
+			t.Errorf("SetGlobal(%#v): %v", test.in, err)
+			continue
+		}
+		got, ok := it.GetGlobal("v")
+		if !ok || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("GetGlobal after SetGlobal(%#v) = %#v, %v, want %#v", test.in, got, ok, test.want)
+		}
+	}
+
+	if _, ok := it.GetGlobal("missing"); ok {
+		t.Error("GetGlobal(missing) is bound")
+	}
+	if err := it.SetGlobal("bad", struct{}{}); err == nil {
+		t.Error("SetGlobal(struct{}{}) did not fail")
+	}
+	if err := it.Exec(context.Background(), "t = (1, 'a')\nd = {1: 2}\n"); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := it.GetGlobal("t"); !reflect.DeepEqual(v, []interface{}{int64(1), "a"}) {
+		t.Errorf("t = %#v", v)
+	}
+	if v, _ := it.GetGlobal("d"); reflect.TypeOf(v) != reflect.TypeOf(&object.Dict{}) {
+		t.Errorf("dict with int keys = %#v, want the *object.Dict", v)
+	}
+}
+
+func TestEval(t *testing.T) {
+	it, _, _ := newTest()
+	it.SetGlobal("x", 20)
+	v, err := it.Eval("x * 2 + 2")
+	if err != nil || v != int64(42) {
+		t.Errorf("Eval = %v, %v", v, err)
+	}
+	if _, err := it.Eval("undefined"); err == nil {
+		t.Error("Eval(undefined) did not fail")
+	}
+}
+
+func TestRegister(t *testing.T) {
+	it, stdout, _ := newTest()
+	var got []object.Value
+	it.Register("record", func(args []object.Value, kwargs map[string]object.Value) (object.Value, error) {
+		got = append(got, args...)
+		return object.Int(len(args)), nil
+	})
+	it.Register("fail", func(args []object.Value, kwargs map[string]object.Value) (object.Value, error) {
+		return nil, object.Errorf(object.ValueError, "from Go")
+	})
+	src := "print(record(1, 'a'))\ntry:\n    fail()\nexcept ValueError as e:\n    print('caught', e)\n"
+	if err := it.Exec(context.Background(), src); err != nil {
+		t.Fatal(err)
+	}
+	if want := "2\ncaught from Go\n"; stdout.String() != want {
+		t.Errorf("stdout = %q, want %q", stdout.String(), want)
+	}
+	if len(got) != 2 || got[0] != object.Int(1) || got[1] != object.Str("a") {
+		t.Errorf("record got %v", got)
+	}
+
+	// a global hides a registered function of the same name
+	if err := it.Exec(context.Background(), "record = 0\n"); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := it.Eval("record"); v != int64(0) {
+		t.Errorf("record = %v, want the global", v)
+	}
+}
+
+func TestPanic(t *testing.T) {
+	it, stdout, stderr := newTest()
+	it.Register("crash", func(args []object.Value, kwargs map[string]object.Value) (object.Value, error) {
+		panic("crash from Go")
+	})
+	src := "def f():\n    try:\n        crash()\n    finally:\n        print('not reached')\nf()\n"
+	err := it.Exec(context.Background(), src)
+	var exc *object.Exception
+	if !errors.As(err, &exc) || exc.Class != object.SystemError {
+		t.Fatalf("Exec = %v, want a SystemError", err)
+	}
+	if !strings.Contains(stderr.String(), "SystemError: crash from Go") {
+		t.Errorf("stderr = %q", stderr.String())
+	}
+	if _, err := it.Eval("crash()"); err == nil {
+		t.Error("Eval(crash()) did not fail")
+	}
+
+	// the interpreter still works after the panic
+	stdout.Reset()
+	if err := it.Exec(context.Background(), "try:\n    1 / 0\nexcept ZeroDivisionError:\n    print('ok')\n"); err != nil {
+		t.Fatal(err)
+	}
+	if stdout.String() != "ok\n" {
+		t.Errorf("stdout = %q, want %q", stdout.String(), "ok\n")
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"test1/compiler"
+	"test1/evaluator"
+	"test1/object"
+	"test1/parser"
+	"test1/tokenizer"
+	"test1/vm"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "write the output of the vm to the .out files")
+
+// run a program with one of the engines, return what it print on its
+// standard outputs
+func run(path, source, stdin string, useEvaluator bool) string {
+	var out bytes.Buffer
+	module, err := parser.Parse(tokenizer.NewLexer(source))
+	if err == nil {
+		if useEvaluator {
+			e := evaluator.New()
+			e.Stdin, e.Stdout, e.Stderr = strings.NewReader(stdin), &out, &out
+			err = e.Run(module)
+		} else {
+			var code *compiler.Code
+			if code, err = compiler.Compile(module); err == nil {
+				m := vm.New()
+				m.Stdin, m.Stdout, m.Stderr = strings.NewReader(stdin), &out, &out
+				err = m.Run(code)
+			}
+		}
+	}
+	if err != nil {
+		out.WriteString(object.FormatException(object.AsException(err), path, strings.Split(source, "\n")))
+	}
+	return out.String()
+}
+
+// first line where got and want differ
+func firstDiff(got, want string) string {
+	g, w := strings.Split(got, "\n"), strings.Split(want, "\n")
+	for i := 0; i < len(g) || i < len(w); i++ {
+		var gl, wl string
+		if i < len(g) {
+			gl = g[i]
+		}
+		if i < len(w) {
+			wl = w[i]
+		}
+		if gl != wl {
+			return fmt.Sprintf("line %d: got %q, want %q", i+1, gl, wl)
+		}
+	}
+	return ""
+}
+
+// Run x.in with both the evaluator and the vm when x.out exists, and compare
+// what they print with it. x.stdin is the standard input if present. An
+// uncaught exception is part of the output, after the traceback header.
+// -update write the output of the vm to the .out files
+func TestGolden(t *testing.T) {
+	paths, _ := filepath.Glob("*.in")
+	for _, path := range paths {
+		base := strings.TrimSuffix(path, ".in")
+		want, err := os.ReadFile(base + ".out")
+		if err != nil && !*update {
+			continue
+		}
+		source, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stdin, _ := os.ReadFile(base + ".stdin")
+		// the tracebacks name the programs as run from the repository root
+		name := filepath.Join("main", path)
+
+		if *update {
+			got := run(name, string(source), string(stdin), false)
+			if err := os.WriteFile(base+".out", []byte(got), 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		for _, engine := range []struct {
+			name         string
+			useEvaluator bool
+		}{{"vm", false}, {"evaluator", true}} {
+			t.Run(base+"/"+engine.name, func(t *testing.T) {
+				got := run(name, string(source), string(stdin), engine.useEvaluator)
+				if got != string(want) {
+					t.Errorf("%s %s", name, firstDiff(got, string(want)))
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"test1/compiler"
+	"test1/evaluator"
+	"test1/object"
+	"test1/parser"
+	. "test1/tokenizer"
+	"test1/vm"
+)
+
+// interactive mode, started when no input file is given. The globals are
+// kept from one input to the next and errors are printed without exiting
+func repl() {
+	// input() reads the lines after the statement from the same buffer
+	in := bufio.NewReader(os.Stdin)
+	ev := evaluator.New()
+	machine := vm.New()
+	ev.Stdin, machine.Stdin = in, in
+	for {
+		source, ok := readInput(in)
+		if !ok {
+			fmt.Println()
+			return
+		}
+		if strings.TrimSpace(source) == "" {
+			continue
+		}
+		if err := runInput(source, ev, machine); err != nil {
+			exc := object.AsException(err)
+			// like python only syntax errors show the source line
+			var lines []string
+			if object.IsSubclass(exc.Class, object.SyntaxError) {
+				lines = strings.Split(source, "\n")
+			}
+			fmt.Fprint(os.Stderr, object.FormatException(exc, "<stdin>", lines))
+		}
+	}
+}
+
+// parse and run one input, expression statements echo their repr
+func runInput(source string, ev *evaluator.Evaluator, machine *vm.VM) error {
+	module, err := parser.Parse(NewLexer(source))
+	if err != nil {
+		return err
+	}
+	if *useEvaluator {
+		return ev.RunInteractive(module)
+	}
+	code, err := compiler.CompileInteractive(module)
+	if err != nil {
+		return err
+	}
+	if *disassemble {
+		fmt.Print(code.Disassemble())
+		return nil
+	}
+	return machine.Run(code)
+}
+
+// read one statement, asking for more lines with "..." until a blank line
+// end a compound statement. ok is false at the end of the input
+func readInput(in *bufio.Reader) (source string, ok bool) {
+	var lines []string
+	prompt := ">>> "
+	for {
+		fmt.Print(prompt)
+		line, err := in.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			if len(lines) == 0 {
+				return "", false
+			}
+			fmt.Println()
+			break
+		}
+		line = strings.TrimRight(line, "\r\n")
+		lines = append(lines, line)
+		more, unclosed := incomplete(strings.Join(lines, "\n") + "\n")
+		if !more {
+			break
+		}
+		// a blank line end a compound statement, not an open bracket
+		if len(lines) > 1 && strings.TrimSpace(line) == "" && !unclosed {
+			break
+		}
+		prompt = "... "
+	}
+	return strings.Join(lines, "\n") + "\n", true
+}
+
+// more is true if the source may go on: a bracket is still open (unclosed
+// is true then), a block is missing after a ":" or the source is a compound
+// statement. A syntax error found before the end of the source is reported
+// at once, other scan errors are left for the parser
+func incomplete(source string) (more, unclosed bool) {
+	_, parseErr := parser.Parse(NewLexer(source))
+	tokens, err := Tokenize(source)
+	if err != nil {
+		e, ok := err.(*ScanError)
+		if !ok || !e.Unclosed {
+			return false, false
+		}
+		// the brackets may be closed on the next lines, unless the parser
+		// failed before reaching the end of the source
+		exc, ok := parseErr.(*object.Exception)
+		return ok && exc.Row == e.Row && exc.Column == e.Column, true
+	}
+	if parseErr != nil {
+		// an error after the last line, ex: the block of an if is missing
+		exc, ok := parseErr.(*object.Exception)
+		return ok && exc.Row > strings.Count(source, "\n"), false
+	}
+	for i, t := range tokens {
+		if t.Category == INDENT {
+			return true, false
+		}
+		if t.Category == COLON && i+1 < len(tokens) && tokens[i+1].Category == NEWLINE {
+			return true, false
+		}
+	}
+	return false, false
+}
@@ -0,0 +1,79 @@
+package object
+
+import "fmt"
+
+// method of a type implemented in Go, self is the value it is called on
+type MethodFunc func(self Value, args []Value, kwargs map[string]Value) (Value, error)
+
+// method bound to a value, ex: the value of x.append
+type Method struct {
+	Name string
+	Self Value
+	Fn   MethodFunc
+}
+
+func (m *Method) Type() *Type { return BuiltinType }
+func (m *Method) Truth() bool { return true }
+func (m *Method) Repr() string {
+	return fmt.Sprintf("<built-in method %s of %s object at %p>", m.Name, m.Self.Type().Name, m.Self)
+}
+
+func (m *Method) Call(args []Value, kwargs map[string]Value) (Value, error) {
+	v, err := m.Fn(m.Self, args, kwargs)
+	if err != nil {
+		return nil, AsException(err)
+	}
+	return v, nil
+}
+
+// value of v.name, the methods of its type bound to v, the __name__ of a
+// type, the attributes of a module and the ones of an exception
+func GetAttr(v Value, name string) (Value, error) {
+	if m, ok := v.(*Module); ok {
+		if attr, ok := m.Attrs[name]; ok {
+			return attr, nil
+		}
+		return nil, Errorf(AttributeError, "module '%s' has no attribute '%s'", m.Name, name)
+	}
+	for t := v.Type(); t != nil; t = t.Base {
+		if fn, ok := t.Methods[name]; ok {
+			return &Method{Name: name, Self: v, Fn: fn}, nil
+		}
+	}
+	if e, ok := v.(*Exception); ok {
+		if attr, ok := e.attr(name); ok {
+			return attr, nil
+		}
+	}
+	if t, ok := v.(*Type); ok {
+		if name == "__name__" {
+			return Str(t.Name), nil
+		}
+		return nil, Errorf(AttributeError, "type object '%s' has no attribute '%s'", t.Name, name)
+	}
+	return nil, Errorf(AttributeError, "'%s' object has no attribute '%s'", v.Type().Name, name)
+}
+
+// v.name = value, or del v.name when value is nil. The values of the
+// builtin types have no attributes that can be changed, a module can get
+// any attribute
+func SetAttr(v Value, name string, value Value) error {
+	if m, ok := v.(*Module); ok {
+		if value != nil {
+			m.Attrs[name] = value
+			return nil
+		}
+		if _, ok := m.Attrs[name]; !ok {
+			return Errorf(AttributeError, "module '%s' has no attribute '%s'", m.Name, name)
+		}
+		delete(m.Attrs, name)
+		return nil
+	}
+	if t, ok := v.(*Type); ok {
+		return Errorf(TypeError, "cannot set '%s' attribute of immutable type '%s'", name, t.Name)
+	}
+	if _, err := GetAttr(v, name); err == nil {
+		return Errorf(AttributeError, "'%s' object attribute '%s' is read-only", v.Type().Name, name)
+	}
+	return Errorf(AttributeError, "'%s' object has no attribute '%s'", v.Type().Name, name)
+}
@@ -0,0 +1,163 @@
+package object
+
+import (
+	"math"
+	"math/big"
+	. "test1/tokenizer"
+)
+
+// int too large for an Int, math/big hold it. Ints are kept normalized: a
+// value that fit in an int64 is always an Int, so the small ints stay on
+// the fast path and a BigInt is never zero
+type BigInt struct {
+	v *big.Int // never changed once the BigInt is made
+}
+
+func (b *BigInt) Type() *Type  { return IntType }
+func (b *BigInt) Repr() string { return b.v.String() }
+func (b *BigInt) Truth() bool  { return true }
+
+// copy of the value
+func (b *BigInt) Big() *big.Int { return new(big.Int).Set(b.v) }
+
+// Int or BigInt holding i, whichever fit. i must not be changed afterwards
+func NewInt(i *big.Int) Value {
+	if i.IsInt64() {
+		return Int(i.Int64())
+	}
+	return &BigInt{v: i}
+}
+
+// big value of Int, Bool and BigInt, the one of a BigInt is shared and must
+// not be changed
+func toBig(v Value) (*big.Int, bool) {
+	if b, ok := v.(*BigInt); ok {
+		return b.v, true
+	}
+	i, ok := toInt(v)
+	if !ok {
+		return nil, false
+	}
+	return big.NewInt(i), true
+}
+
+// Int, Bool or BigInt
+func isInt(v Value) bool {
+	_, ok := toBig(v)
+	return ok
+}
+
+// int value of an int clamped to the int64 range, for the bounds of a
+// slice where a big int is past either end
+func clampInt(v Value) (int64, bool) {
+	if b, ok := v.(*BigInt); ok {
+		if b.v.Sign() < 0 {
+			return math.MinInt64, true
+		}
+		return math.MaxInt64, true
+	}
+	return toInt(v)
+}
+
+// nearest float of a big int, inf when it is too large
+func bigToFloat(i *big.Int) float64 {
+	f, _ := new(big.Float).SetInt(i).Float64()
+	return f
+}
+
+// OverflowError when one of the values is an int too large for a float
+func floatOverflow(values ...Value) error {
+	for _, v := range values {
+		if b, ok := v.(*BigInt); ok && math.IsInf(bigToFloat(b.v), 0) {
+			return Errorf(OverflowError, "int too large to convert to float")
+		}
+	}
+	return nil
+}
+
+// x op y on ints of any size, nil when it is not an int operator
+func bigOp(op int, x, y *big.Int) (Value, error) {
+	z := new(big.Int)
+	switch op {
+	case PLUS:
+		return NewInt(z.Add(x, y)), nil
+	case MINUS:
+		return NewInt(z.Sub(x, y)), nil
+	case TIMES:
+		return NewInt(z.Mul(x, y)), nil
+	case DIV:
+		if y.Sign() == 0 {
+			return nil, Errorf(ZeroDivisionError, "division by zero")
+		}
+		// the fraction is exact so the quotient is correctly rounded
+		f, _ := new(big.Rat).SetFrac(x, y).Float64()
+		if math.IsInf(f, 0) {
+			return nil, Errorf(OverflowError, "integer division result too large for a float")
+		}
+		// a zero quotient keep the sign, 0 / -5 is -0.0
+		if f == 0 && (x.Sign() < 0) != (y.Sign() < 0) {
+			f = math.Copysign(0, -1)
+		}
+		return Float(f), nil
+	case FLOORDIV, MOD:
+		if y.Sign() == 0 {
+			if op == MOD {
+				return nil, Errorf(ZeroDivisionError, "integer modulo by zero")
+			}
+			return nil, Errorf(ZeroDivisionError, "integer division or modulo by zero")
+		}
+		// QuoRem truncate toward zero, the remainder take the sign of the divisor
+		r := new(big.Int)
+		z.QuoRem(x, y, r)
+		if r.Sign() != 0 && r.Sign() != y.Sign() {
+			z.Sub(z, big.NewInt(1))
+			r.Add(r, y)
+		}
+		if op == MOD {
+			return NewInt(r), nil
+		}
+		return NewInt(z), nil
+	case POWER:
+		if y.Sign() < 0 {
+			if err := floatOverflow(&BigInt{v: x}); err != nil {
+				return nil, err
+			}
+			return floatOp(op, bigToFloat(x), bigToFloat(y))
+		}
+		return NewInt(z.Exp(x, y, nil)), nil
+	case LSHIFT, RSHIFT:
+		if y.Sign() < 0 {
+			return nil, Errorf(ValueError, "negative shift count")
+		}
+		if op == RSHIFT {
+			if !y.IsInt64() || y.Int64() > int64(x.BitLen()) {
+				// everything is shifted out, only the sign is left
+				return Int(x.Sign() >> 1), nil
+			}
+			return NewInt(z.Rsh(x, uint(y.Int64()))), nil
+		}
+		if x.Sign() == 0 {
+			return Int(0), nil
+		}
+		if !y.IsInt64() || y.Int64() > math.MaxInt32 {
+			return nil, Errorf(OverflowError, "too many digits in integer")
+		}
+		return NewInt(z.Lsh(x, uint(y.Int64()))), nil
+	case BITAND:
+		return NewInt(z.And(x, y)), nil
+	case BITOR:
+		return NewInt(z.Or(x, y)), nil
+	case BITXOR:
+		return NewInt(z.Xor(x, y)), nil
+	}
+	return nil, nil
+}
+
+// hash of a big int, reduced modulo hashModulus like the small ones
+func hashBig(i *big.Int) int64 {
+	h := new(big.Int).Mod(new(big.Int).Abs(i), big.NewInt(hashModulus)).Int64()
+	if i.Sign() < 0 {
+		return fixHash(-h)
+	}
+	return h
+}
@@ -0,0 +1,730 @@
+package object
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	. "test1/tokenizer"
+)
+
+// Go function callable from Python, kwargs hold the keyword arguments and
+// may be nil. args must not be kept after the function return
+type BuiltinFunc func(args []Value, kwargs map[string]Value) (Value, error)
+
+// builtin function value, ex: len
+type Builtin struct {
+	Name string
+	Fn   BuiltinFunc
+}
+
+var BuiltinType = &Type{Name: "builtin_function_or_method"}
+
+func (b *Builtin) Type() *Type  { return BuiltinType }
+func (b *Builtin) Repr() string { return "<built-in function " + b.Name + ">" }
+func (b *Builtin) Truth() bool  { return true }
+
+// call the function, errors that are not exceptions become RuntimeError
+func (b *Builtin) Call(args []Value, kwargs map[string]Value) (Value, error) {
+	v, err := b.Fn(args, kwargs)
+	if err != nil {
+		return nil, AsException(err)
+	}
+	return v, nil
+}
+
+// names every interpreter start with, see NewBuiltins
+var defaultBuiltins = map[string]Value{}
+
+func init() {
+	IntType.New = newInt
+	FloatType.New = newFloat
+	BoolType.New = newBool
+	StrType.New = newStr
+	TypeType.New = newType
+	for _, t := range []*Type{IntType, FloatType, BoolType, StrType, TypeType, ListType, TupleType,
+		DictType, SetType, RangeType} {
+		defaultBuiltins[t.Name] = t
+	}
+	for name, fn := range map[string]BuiltinFunc{
+		"len": builtinLen, "abs": builtinAbs, "min": builtinMin, "max": builtinMax,
+		"round": builtinRound, "isinstance": builtinIsinstance, "repr": builtinRepr,
+		"hash": builtinHash, "iter": builtinIter, "next": builtinNext, "open": builtinOpen,
+	} {
+		defaultBuiltins[name] = &Builtin{Name: name, Fn: fn}
+	}
+	for _, cls := range []*Type{BaseException, ExceptionType, ArithmeticError, ZeroDivisionError,
+		OverflowError, AttributeError, LookupError, IndexError, KeyError, NameError,
+		UnboundLocalError, OSError, RuntimeError, SystemError, RecursionError, NotImplementedError,
+		SyntaxError, IndentationError, TypeError, ValueError, EOFError, KeyboardInterrupt,
+		StopIteration, ImportError, ModuleNotFoundError, FileNotFoundError, FileExistsError,
+		IsADirectoryError, PermissionError, MemoryError} {
+		defaultBuiltins[cls.Name] = cls
+	}
+}
+
+// the builtin names of a new interpreter, the last scope of the name lookup.
+// Each interpreter get its own copy so it can register more functions, the
+// I/O functions use its streams
+func NewBuiltins(streams *Streams) map[string]Value {
+	builtins := make(map[string]Value, len(defaultBuiltins)+3)
+	for name, v := range defaultBuiltins {
+		builtins[name] = v
+	}
+	builtins["input"] = &Builtin{Name: "input", Fn: streams.input}
+	builtins["print"] = &Builtin{Name: "print", Fn: streams.print}
+	builtins["__import__"] = &Builtin{Name: "__import__", Fn: streams.importModule}
+	return builtins
+}
+
+// standard input and outputs of an interpreter, they can be changed between
+// two runs
+type Streams struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	in     *bufio.Reader // buffer on Stdin, made again when Stdin change
+	inFrom io.Reader
+	sys    *Module // made by the first import
+}
+
+func StandardStreams() Streams {
+	return Streams{Stdin: os.Stdin, Stdout: os.Stdout, Stderr: os.Stderr}
+}
+
+// the buffered reader of the current Stdin. A Stdin that is already a
+// *bufio.Reader is used as it is, so a program reading it too, like the
+// REPL, does not lose what input() read ahead
+func (s *Streams) reader() *bufio.Reader {
+	if s.in == nil || s.inFrom != s.Stdin {
+		in, ok := s.Stdin.(*bufio.Reader)
+		if !ok {
+			in = bufio.NewReader(s.Stdin)
+		}
+		s.in, s.inFrom = in, s.Stdin
+	}
+	return s.in
+}
+
+// input([prompt]): write the prompt then read a line without its newline
+func (s *Streams) input(args []Value, kwargs map[string]Value) (Value, error) {
+	if err := noKeywords("input", kwargs); err != nil {
+		return nil, err
+	}
+	if len(args) > 1 {
+		return nil, Errorf(TypeError, "input expected at most 1 argument, got %d", len(args))
+	}
+	if len(args) == 1 {
+		io.WriteString(s.Stdout, StrOf(args[0]))
+	}
+	line, err := s.reader().ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		if err == io.EOF {
+			return nil, Errorf(EOFError, "EOF when reading a line")
+		}
+		return nil, Errorf(OSError, "%s", err)
+	}
+	return Str(strings.TrimSuffix(line, "\n")), nil
+}
+
+func noKeywords(name string, kwargs map[string]Value) error {
+	if len(kwargs) > 0 {
+		return Errorf(TypeError, "%s() takes no keyword arguments", name)
+	}
+	return nil
+}
+
+// int(x=0, base=10)
+func newInt(args []Value, kwargs map[string]Value) (Value, error) {
+	var x, base Value
+	if len(args) > 2 {
+		return nil, Errorf(TypeError, "int() takes at most 2 arguments (%d given)", len(args))
+	}
+	if len(args) > 0 {
+		x = args[0]
+	}
+	if len(args) > 1 {
+		base = args[1]
+	}
+	for name, v := range kwargs {
+		if name != "base" {
+			return nil, Errorf(TypeError, "'%s' is an invalid keyword argument for int()", name)
+		}
+		if base != nil {
+			return nil, Errorf(TypeError, "argument for int() given by name ('base') and position (2)")
+		}
+		base = v
+	}
+
+	if base == nil {
+		switch n := x.(type) {
+		case nil:
+			return Int(0), nil
+		case Int, *BigInt:
+			return n, nil
+		case Bool:
+			i, _ := toInt(n)
+			return Int(i), nil
+		case Float:
+			return floatToInt(float64(n))
+		case Str:
+			return parseInt(string(n), 10)
+		}
+		return nil, Errorf(TypeError, "int() argument must be a string, a bytes-like object or a real number, not '%s'", x.Type().Name)
+	}
+
+	b, ok := toInt(base)
+	if !ok {
+		return nil, Errorf(TypeError, "'%s' object cannot be interpreted as an integer", base.Type().Name)
+	}
+	if b != 0 && (b < 2 || b > 36) {
+		return nil, Errorf(ValueError, "int() base must be >= 2 and <= 36, or 0")
+	}
+	s, ok := x.(Str)
+	if !ok {
+		if x == nil {
+			return nil, Errorf(TypeError, "int() missing string argument")
+		}
+		return nil, Errorf(TypeError, "int() can't convert non-string with explicit base")
+	}
+	return parseInt(string(s), int(b))
+}
+
+// int of a float, truncated toward zero
+func floatToInt(f float64) (Value, error) {
+	switch {
+	case math.IsNaN(f):
+		return nil, Errorf(ValueError, "cannot convert float NaN to integer")
+	case math.IsInf(f, 0):
+		return nil, Errorf(OverflowError, "cannot convert float infinity to integer")
+	case f >= math.MaxInt64 || f < math.MinInt64:
+		// a float this large has no fractional part
+		i, _ := big.NewFloat(f).Int(nil)
+		return NewInt(i), nil
+	}
+	return Int(int64(f)), nil
+}
+
+// parse an int literal like python: spaces around, a sign, "_" between
+// digits and for base 0 a 0x, 0o or 0b prefix that also choose the base
+func parseInt(text string, base int) (Value, error) {
+	invalid := Errorf(ValueError, "invalid literal for int() with base %d: %s", base, Str(text).Repr())
+	s := strings.TrimSpace(text)
+	negative := false
+	if s != "" && (s[0] == '+' || s[0] == '-') {
+		negative = s[0] == '-'
+		s = s[1:]
+	}
+
+	// optional prefix, required to pick the base when base is 0
+	if len(s) >= 2 && s[0] == '0' {
+		prefixBase := map[byte]int{'x': 16, 'X': 16, 'o': 8, 'O': 8, 'b': 2, 'B': 2}[s[1]]
+		if prefixBase != 0 && (base == 0 || base == prefixBase) {
+			base = prefixBase
+			s = s[2:]
+			// "_" may follow the prefix
+			if strings.HasPrefix(s, "_") {
+				s = s[1:]
+			}
+		}
+	}
+	if base == 0 {
+		// no prefix: decimal, and a leading 0 is only allowed for zero
+		base = 10
+		if strings.HasPrefix(s, "0") && strings.Trim(s, "0_") != "" {
+			return nil, invalid
+		}
+	}
+
+	// "_" only between digits
+	if s == "" || strings.HasPrefix(s, "_") || strings.HasSuffix(s, "_") || strings.Contains(s, "__") {
+		return nil, invalid
+	}
+	s = strings.ReplaceAll(s, "_", "")
+	if negative {
+		s = "-" + s
+	}
+	i, err := strconv.ParseInt(s, base, 64)
+	if err != nil {
+		if err.(*strconv.NumError).Err == strconv.ErrRange {
+			b, _ := new(big.Int).SetString(s, base)
+			return NewInt(b), nil
+		}
+		return nil, invalid
+	}
+	return Int(i), nil
+}
+
+// value that can be called from a builtin, ex: the key function of min()
+type Callable interface {
+	Value
+	Call(args []Value, kwargs map[string]Value) (Value, error)
+}
+
+// value with a length, ex: str
+type Sized interface {
+	Value
+	Len() int
+}
+
+// TypeError unless exactly n positional arguments are given
+func exactArgs(name string, args []Value, n int) error {
+	if len(args) == n {
+		return nil
+	}
+	if n == 1 {
+		return Errorf(TypeError, "%s() takes exactly one argument (%d given)", name, len(args))
+	}
+	return Errorf(TypeError, "%s expected %d arguments, got %d", name, n, len(args))
+}
+
+// TypeError when more than n positional arguments are given
+func atMostArgs(name string, args []Value, n int) error {
+	if len(args) > n {
+		return Errorf(TypeError, "%s expected at most %d argument%s, got %d", name, n, plural(n), len(args))
+	}
+	return nil
+}
+
+func plural(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
+
+// float(x=0.0)
+func newFloat(args []Value, kwargs map[string]Value) (Value, error) {
+	if err := noKeywords("float", kwargs); err != nil {
+		return nil, err
+	}
+	if err := atMostArgs("float", args, 1); err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		return Float(0), nil
+	}
+	switch x := args[0].(type) {
+	case Float:
+		return x, nil
+	case Int, Bool, *BigInt:
+		if err := floatOverflow(x); err != nil {
+			return nil, err
+		}
+		f, _ := toFloat(x)
+		return Float(f), nil
+	case Str:
+		return parseFloat(string(x))
+	}
+	return nil, Errorf(TypeError, "float() argument must be a string or a real number, not '%s'", args[0].Type().Name)
+}
+
+// parse a float like python: spaces around, "_" between digits, inf,
+// infinity and nan in any case
+func parseFloat(text string) (Value, error) {
+	invalid := Errorf(ValueError, "could not convert string to float: %s", Str(text).Repr())
+	s := strings.TrimSpace(text)
+	body := strings.TrimLeft(s, "+-")
+	if len(s)-len(body) > 1 {
+		return nil, invalid
+	}
+	switch strings.ToLower(body) {
+	case "inf", "infinity":
+		if strings.HasPrefix(s, "-") {
+			return Float(math.Inf(-1)), nil
+		}
+		return Float(math.Inf(1)), nil
+	case "nan":
+		return Float(math.NaN()), nil
+	}
+	// "_" only between digits, go also accept hex floats and "0x" so
+	// only keep digits, ".", "e" and signs after "e"
+	for i, r := range body {
+		ok := r >= '0' && r <= '9' || r == '.' || r == 'e' || r == 'E' ||
+			(r == '+' || r == '-') && i > 0 && (body[i-1] == 'e' || body[i-1] == 'E')
+		if r == '_' {
+			ok = i > 0 && i < len(body)-1 && isDigit(body[i-1]) && isDigit(body[i+1])
+		}
+		if !ok {
+			return nil, invalid
+		}
+	}
+	f, err := strconv.ParseFloat(strings.ReplaceAll(s, "_", ""), 64)
+	if err != nil && err.(*strconv.NumError).Err != strconv.ErrRange {
+		return nil, invalid
+	}
+	return Float(f), nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// bool(x=False)
+func newBool(args []Value, kwargs map[string]Value) (Value, error) {
+	if err := noKeywords("bool", kwargs); err != nil {
+		return nil, err
+	}
+	if err := atMostArgs("bool", args, 1); err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		return False, nil
+	}
+	return Bool(args[0].Truth()), nil
+}
+
+// str(object=”)
+func newStr(args []Value, kwargs map[string]Value) (Value, error) {
+	for name, v := range kwargs {
+		if name != "object" || len(args) > 0 {
+			return nil, Errorf(TypeError, "'%s' is an invalid keyword argument for str()", name)
+		}
+		args = []Value{v}
+	}
+	if len(args) > 1 {
+		return nil, Errorf(TypeError, "str() takes at most 1 argument (%d given)", len(args))
+	}
+	if len(args) == 0 {
+		return Str(""), nil
+	}
+	return Str(StrOf(args[0])), nil
+}
+
+// type(object), the class of the object
+func newType(args []Value, kwargs map[string]Value) (Value, error) {
+	if len(args) != 1 || len(kwargs) > 0 {
+		return nil, Errorf(TypeError, "type() takes 1 or 3 arguments")
+	}
+	return args[0].Type(), nil
+}
+
+// len(obj)
+func builtinLen(args []Value, kwargs map[string]Value) (Value, error) {
+	if err := noKeywords("len", kwargs); err != nil {
+		return nil, err
+	}
+	if err := exactArgs("len", args, 1); err != nil {
+		return nil, err
+	}
+	if r, ok := args[0].(*Range); ok {
+		return r.lenValue()
+	}
+	if s, ok := args[0].(Sized); ok {
+		return Int(s.Len()), nil
+	}
+	return nil, Errorf(TypeError, "object of type '%s' has no len()", args[0].Type().Name)
+}
+
+// abs(x)
+func builtinAbs(args []Value, kwargs map[string]Value) (Value, error) {
+	if err := noKeywords("abs", kwargs); err != nil {
+		return nil, err
+	}
+	if err := exactArgs("abs", args, 1); err != nil {
+		return nil, err
+	}
+	switch x := args[0].(type) {
+	case Float:
+		return Float(math.Abs(float64(x))), nil
+	case Int, Bool:
+		i, _ := toInt(x)
+		if i < 0 {
+			return UnaryOp(MINUS, Int(i))
+		}
+		return Int(i), nil
+	case *BigInt:
+		return NewInt(new(big.Int).Abs(x.v)), nil
+	}
+	return nil, Errorf(TypeError, "bad operand type for abs(): '%s'", args[0].Type().Name)
+}
+
+// min(iterable, *[, default, key]) or min(arg1, arg2, *args[, key])
+func builtinMin(args []Value, kwargs map[string]Value) (Value, error) {
+	return minmax("min", LESSTHAN, args, kwargs)
+}
+
+// max(iterable, *[, default, key]) or max(arg1, arg2, *args[, key])
+func builtinMax(args []Value, kwargs map[string]Value) (Value, error) {
+	return minmax("max", GREATERTHAN, args, kwargs)
+}
+
+// the first item that no other item is better than, op is the comparison
+// saying an item is better
+func minmax(name string, op int, args []Value, kwargs map[string]Value) (Value, error) {
+	var key, deflt Value
+	for k, v := range kwargs {
+		switch k {
+		case "key":
+			key = v
+		case "default":
+			deflt = v
+		default:
+			return nil, Errorf(TypeError, "%s() got an unexpected keyword argument '%s'", name, k)
+		}
+	}
+	if key == None {
+		key = nil
+	}
+	items := args
+	switch len(args) {
+	case 0:
+		return nil, Errorf(TypeError, "%s expected at least 1 argument, got 0", name)
+	case 1:
+		var err error
+		if items, err = Iterate(args[0]); err != nil {
+			return nil, err
+		}
+	default:
+		if deflt != nil {
+			return nil, Errorf(TypeError, "Cannot specify a default for %s() with multiple positional arguments", name)
+		}
+	}
+	if len(items) == 0 {
+		if deflt != nil {
+			return deflt, nil
+		}
+		return nil, Errorf(ValueError, "%s() arg is an empty sequence", name)
+	}
+
+	var best, bestKey Value
+	for _, item := range items {
+		k := item
+		if key != nil {
+			f, ok := key.(Callable)
+			if !ok {
+				return nil, Errorf(TypeError, "'%s' object is not callable", key.Type().Name)
+			}
+			var err error
+			if k, err = f.Call([]Value{item}, nil); err != nil {
+				return nil, err
+			}
+		}
+		if best == nil {
+			best, bestKey = item, k
+			continue
+		}
+		better, err := Compare(op, k, bestKey)
+		if err != nil {
+			return nil, err
+		}
+		if better.Truth() {
+			best, bestKey = item, k
+		}
+	}
+	return best, nil
+}
+
+// the items of an iterable value
+func Iterate(v Value) ([]Value, error) {
+	switch x := v.(type) {
+	case Str:
+		var items []Value
+		for _, r := range string(x) {
+			items = append(items, Str(string(r)))
+		}
+		return items, nil
+	case *List:
+		return append([]Value(nil), x.Items...), nil
+	case *Tuple:
+		return append([]Value(nil), x.Items...), nil
+	case *Dict:
+		return x.Keys(), nil
+	case *DictView:
+		return x.Items(), nil
+	case *Set:
+		return x.Items(), nil
+	}
+	it, err := Iter(v)
+	if err != nil {
+		return nil, err
+	}
+	var items []Value
+	for {
+		item, ok, err := it.Next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return items, nil
+		}
+		items = append(items, item)
+	}
+}
+
+// the items of the value of a starred item in a display, ex: [*v]
+func StarredItems(v Value) ([]Value, error) {
+	items, err := Iterate(v)
+	if err != nil {
+		return nil, Errorf(TypeError, "Value after * must be an iterable, not %s", v.Type().Name)
+	}
+	return items, nil
+}
+
+// the values for a target list of before targets, an optional starred
+// target and after targets, ex: a, *b, c = v. The starred target get a list
+// of the items between the others
+func Unpack(v Value, before, after int, starred bool) ([]Value, error) {
+	items, err := Iterate(v)
+	if err != nil {
+		return nil, Errorf(TypeError, "cannot unpack non-iterable %s object", v.Type().Name)
+	}
+	n := before + after
+	if !starred {
+		if len(items) < n {
+			return nil, Errorf(ValueError, "not enough values to unpack (expected %d, got %d)", n, len(items))
+		}
+		if len(items) > n {
+			return nil, Errorf(ValueError, "too many values to unpack (expected %d)", n)
+		}
+		return items, nil
+	}
+	if len(items) < n {
+		return nil, Errorf(ValueError, "not enough values to unpack (expected at least %d, got %d)", n, len(items))
+	}
+	rest := &List{Items: append([]Value(nil), items[before:len(items)-after]...)}
+	values := append(items[:before:before], rest)
+	return append(values, items[len(items)-after:]...), nil
+}
+
+// round(number, ndigits=None)
+func builtinRound(args []Value, kwargs map[string]Value) (Value, error) {
+	var number, ndigits Value
+	for k, v := range kwargs {
+		switch k {
+		case "number":
+			number = v
+		case "ndigits":
+			ndigits = v
+		default:
+			return nil, Errorf(TypeError, "round() got an unexpected keyword argument '%s'", k)
+		}
+	}
+	if len(args) > 2 {
+		return nil, Errorf(TypeError, "round() takes at most 2 arguments (%d given)", len(args))
+	}
+	if len(args) > 0 {
+		number = args[0]
+	}
+	if len(args) > 1 {
+		ndigits = args[1]
+	}
+	if number == nil {
+		return nil, Errorf(TypeError, "round() missing required argument 'number' (pos 1)")
+	}
+	if ndigits == None {
+		ndigits = nil
+	}
+	var n int64
+	if ndigits != nil {
+		var ok bool
+		if n, ok = toInt(ndigits); !ok {
+			return nil, Errorf(TypeError, "'%s' object cannot be interpreted as an integer", ndigits.Type().Name)
+		}
+	}
+
+	switch x := number.(type) {
+	case Float:
+		f := float64(x)
+		if ndigits == nil {
+			if math.IsNaN(f) || math.IsInf(f, 0) {
+				return floatToInt(f)
+			}
+			return floatToInt(math.RoundToEven(f))
+		}
+		return Float(roundFloat(f, n)), nil
+	case Int, Bool, *BigInt:
+		i, _ := toBig(x)
+		if n >= 0 {
+			return NewInt(new(big.Int).Set(i)), nil
+		}
+		return roundInt(i, n), nil
+	}
+	return nil, Errorf(TypeError, "type %s doesn't define __round__ method", number.Type().Name)
+}
+
+// round f to n decimal digits, ties go to the even digit like python
+func roundFloat(f float64, n int64) float64 {
+	if math.IsNaN(f) || math.IsInf(f, 0) || f == 0 {
+		return f
+	}
+	if n >= 0 {
+		if n > 330 {
+			return f
+		}
+		// FormatFloat round the exact binary value, like python does
+		r, _ := strconv.ParseFloat(strconv.FormatFloat(f, 'f', int(n), 64), 64)
+		return r
+	}
+	if n < -308 {
+		return math.Copysign(0, f)
+	}
+	p := math.Pow(10, float64(-n))
+	return math.Copysign(math.RoundToEven(f/p)*p, f)
+}
+
+// round i to a multiple of 10**-n, n is negative, ties go to the even multiple
+func roundInt(i *big.Int, n int64) Value {
+	// fewer digits than -n always round to 0, 10**-n is not needed
+	if int64(len(i.String())) < -n {
+		return Int(0)
+	}
+	p := new(big.Int).Exp(big.NewInt(10), big.NewInt(-n), nil)
+	// floored quotient and remainder, the remainder is in [0, p)
+	q, r := new(big.Int).DivMod(i, p, new(big.Int))
+	switch r.Lsh(r, 1).Cmp(p) {
+	case 1:
+		q.Add(q, big.NewInt(1))
+	case 0:
+		if q.Bit(0) != 0 {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return NewInt(q.Mul(q, p))
+}
+
+// isinstance(obj, class_or_tuple)
+func builtinIsinstance(args []Value, kwargs map[string]Value) (Value, error) {
+	if err := noKeywords("isinstance", kwargs); err != nil {
+		return nil, err
+	}
+	if err := exactArgs("isinstance", args, 2); err != nil {
+		return nil, err
+	}
+	match, err := isInstance(args[0], args[1])
+	if err != nil {
+		return nil, err
+	}
+	return Bool(match), nil
+}
+
+// the tuples can be nested, their items are checked until one match
+func isInstance(v, cls Value) (bool, error) {
+	switch c := cls.(type) {
+	case *Type:
+		return IsSubclass(v.Type(), c), nil
+	case *Tuple:
+		for _, item := range c.Items {
+			if match, err := isInstance(v, item); match || err != nil {
+				return match, err
+			}
+		}
+		return false, nil
+	}
+	return false, Errorf(TypeError, "isinstance() arg 2 must be a type, a tuple of types, or a union")
+}
+
+// repr(obj)
+func builtinRepr(args []Value, kwargs map[string]Value) (Value, error) {
+	if err := noKeywords("repr", kwargs); err != nil {
+		return nil, err
+	}
+	if err := exactArgs("repr", args, 1); err != nil {
+		return nil, err
+	}
+	return Str(args[0].Repr()), nil
+}
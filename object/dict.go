@@ -0,0 +1,603 @@
+package object
+
+import (
+	"hash/fnv"
+	"math"
+	"reflect"
+	"strings"
+)
+
+// mapping that keep its keys in insertion order, keys are found by hash
+// then by Equal so 1, 1.0 and True are the same key
+type Dict struct {
+	entries []dictEntry     // in insertion order, deleted entries have a nil Key
+	index   map[int64][]int // hash to the positions in entries of the keys having it
+	count   int             // number of keys
+
+	inRepr bool // set while Repr run, a dict containing itself print as {...}
+}
+
+type dictEntry struct {
+	Key, Value Value
+	hash       int64
+}
+
+var DictType = &Type{Name: "dict"}
+
+func (d *Dict) Type() *Type { return DictType }
+func (d *Dict) Truth() bool { return d.count > 0 }
+func (d *Dict) Len() int    { return d.count }
+
+func (d *Dict) Repr() string {
+	if d.inRepr {
+		return "{...}"
+	}
+	d.inRepr = true
+	defer func() { d.inRepr = false }()
+	var b strings.Builder
+	b.WriteString("{")
+	for _, e := range d.entries {
+		if e.Key == nil {
+			continue
+		}
+		if b.Len() > 1 {
+			b.WriteString(", ")
+		}
+		b.WriteString(e.Key.Repr() + ": " + e.Value.Repr())
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// position of key in entries, -1 when it is missing
+func (d *Dict) find(key Value) (int, int64, error) {
+	h, err := Hash(key)
+	if err != nil {
+		return -1, 0, err
+	}
+	for _, i := range d.index[h] {
+		if k := d.entries[i].Key; Is(k, key) || Equal(k, key) {
+			return i, h, nil
+		}
+	}
+	return -1, h, nil
+}
+
+// value of key, false when it is missing
+func (d *Dict) Get(key Value) (Value, bool, error) {
+	i, _, err := d.find(key)
+	if err != nil || i < 0 {
+		return nil, false, err
+	}
+	return d.entries[i].Value, true, nil
+}
+
+// bind key to v, a key already there keep its place and its first form
+func (d *Dict) Set(key, v Value) error {
+	i, h, err := d.find(key)
+	if err != nil {
+		return err
+	}
+	if i >= 0 {
+		d.entries[i].Value = v
+		return nil
+	}
+	if d.index == nil {
+		d.index = make(map[int64][]int)
+	}
+	d.index[h] = append(d.index[h], len(d.entries))
+	d.entries = append(d.entries, dictEntry{Key: key, Value: v, hash: h})
+	d.count++
+	return nil
+}
+
+// remove key and return its value, false when it is missing
+func (d *Dict) Delete(key Value) (Value, bool, error) {
+	i, h, err := d.find(key)
+	if err != nil || i < 0 {
+		return nil, false, err
+	}
+	v := d.entries[i].Value
+	d.removeAt(i, h)
+	return v, true, nil
+}
+
+func (d *Dict) removeAt(i int, h int64) {
+	positions := d.index[h]
+	for j, p := range positions {
+		if p == i {
+			positions = append(positions[:j], positions[j+1:]...)
+			break
+		}
+	}
+	if len(positions) == 0 {
+		delete(d.index, h)
+	} else {
+		d.index[h] = positions
+	}
+	d.entries[i] = dictEntry{}
+	d.count--
+
+	// drop the holes once they are most of the entries
+	if len(d.entries) > 8 && d.count < len(d.entries)/2 {
+		live := d.entries[:0]
+		d.index = make(map[int64][]int, d.count)
+		for _, e := range d.entries {
+			if e.Key != nil {
+				d.index[e.hash] = append(d.index[e.hash], len(live))
+				live = append(live, e)
+			}
+		}
+		for j := len(live); j < len(d.entries); j++ {
+			d.entries[j] = dictEntry{}
+		}
+		d.entries = live
+	}
+}
+
+// the keys in insertion order
+func (d *Dict) Keys() []Value {
+	keys := make([]Value, 0, d.count)
+	for _, e := range d.entries {
+		if e.Key != nil {
+			keys = append(keys, e.Key)
+		}
+	}
+	return keys
+}
+
+func (d *Dict) values() []Value {
+	values := make([]Value, 0, d.count)
+	for _, e := range d.entries {
+		if e.Key != nil {
+			values = append(values, e.Value)
+		}
+	}
+	return values
+}
+
+func (d *Dict) items() []Value {
+	items := make([]Value, 0, d.count)
+	for _, e := range d.entries {
+		if e.Key != nil {
+			items = append(items, &Tuple{Items: []Value{e.Key, e.Value}})
+		}
+	}
+	return items
+}
+
+func (d *Dict) GetItem(key Value) (Value, error) {
+	v, ok, err := d.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, &Exception{Class: KeyError, Args: []Value{key}}
+	}
+	return v, nil
+}
+
+func (d *Dict) SetItem(key, v Value) error {
+	return d.Set(key, v)
+}
+
+func (d *Dict) DelItem(key Value) error {
+	_, ok, err := d.Delete(key)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return &Exception{Class: KeyError, Args: []Value{key}}
+	}
+	return nil
+}
+
+// same keys bound to equal values, the order does not matter
+func (d *Dict) equal(other *Dict) bool {
+	if d.count != other.count {
+		return false
+	}
+	for _, e := range d.entries {
+		if e.Key == nil {
+			continue
+		}
+		v, ok, _ := other.Get(e.Key)
+		if !ok || (!Is(e.Value, v) && !Equal(e.Value, v)) {
+			return false
+		}
+	}
+	return true
+}
+
+/*##############
+### HASHING ###
+##############*/
+
+// modulus of the numeric hashes, as in CPython
+const hashModulus = 1<<61 - 1
+
+// hash of a value, values that are Equal have the same hash so 1, 1.0 and
+// True hash alike. Mutable containers are unhashable, the other values
+// without a hash of their own are only equal to themselves
+func Hash(v Value) (int64, error) {
+	switch x := v.(type) {
+	case Int:
+		return hashInt(int64(x)), nil
+	case *BigInt:
+		return hashBig(x.v), nil
+	case Bool:
+		if x {
+			return 1, nil
+		}
+		return 0, nil
+	case Float:
+		return hashFloat(float64(x)), nil
+	case Str:
+		h := fnv.New64a()
+		h.Write([]byte(x))
+		return fixHash(int64(h.Sum64())), nil
+	case NoneValue:
+		return 0x5f3759df, nil
+	case *Tuple:
+		return x.hash()
+	case *Range:
+		return x.hash()
+	case *List, *Dict, *Set, *Slice:
+		return 0, unhashable(v)
+	case *DictView:
+		if x.kind != "values" {
+			return 0, unhashable(v)
+		}
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr {
+		return fixHash(int64(rv.Pointer() >> 4)), nil
+	}
+	return 0, unhashable(v)
+}
+
+func unhashable(v Value) *Exception {
+	return Errorf(TypeError, "unhashable type: '%s'", v.Type().Name)
+}
+
+// -1 is not a valid hash in CPython
+func fixHash(h int64) int64 {
+	if h == -1 {
+		return -2
+	}
+	return h
+}
+
+func hashInt(i int64) int64 {
+	if i < 0 {
+		// -(i+1) avoid the overflow of -i for the smallest int64
+		return fixHash(-int64((uint64(-(i + 1)) + 1) % hashModulus))
+	}
+	return int64(uint64(i) % hashModulus)
+}
+
+// the hash of a float that is an integer is the hash of that integer, the
+// other floats are reduced modulo hashModulus like a fraction
+func hashFloat(f float64) int64 {
+	switch {
+	case math.IsInf(f, 1):
+		return 314159
+	case math.IsInf(f, -1):
+		return -314159
+	case math.IsNaN(f):
+		return 0
+	}
+	m, e := math.Frexp(math.Abs(f))
+	var x uint64
+	for m != 0 {
+		x = (x<<28)&hashModulus | x>>(61-28)
+		m *= 1 << 28
+		e -= 28
+		y := uint64(m)
+		m -= float64(y)
+		x += y
+		if x >= hashModulus {
+			x -= hashModulus
+		}
+	}
+	if e >= 0 {
+		e %= 61
+	} else {
+		e = 61 - 1 - (-1-e)%61
+	}
+	x = (x<<uint(e))&hashModulus | x>>uint(61-e)
+	h := int64(x)
+	if f < 0 {
+		h = -h
+	}
+	return fixHash(h)
+}
+
+/*############
+### VIEWS ###
+############*/
+
+// live view of the keys, the values or the items of a dict
+type DictView struct {
+	d    *Dict
+	kind string // "keys", "values" or "items"
+}
+
+var (
+	DictKeysType   = &Type{Name: "dict_keys"}
+	DictValuesType = &Type{Name: "dict_values"}
+	DictItemsType  = &Type{Name: "dict_items"}
+)
+
+func (v *DictView) Type() *Type {
+	switch v.kind {
+	case "keys":
+		return DictKeysType
+	case "values":
+		return DictValuesType
+	}
+	return DictItemsType
+}
+
+func (v *DictView) Truth() bool { return v.d.count > 0 }
+func (v *DictView) Len() int    { return v.d.count }
+
+// dict_keys([1, 2])
+func (v *DictView) Repr() string {
+	if v.d.inRepr {
+		return v.Type().Name + "([...])"
+	}
+	v.d.inRepr = true
+	defer func() { v.d.inRepr = false }()
+	items := v.Items()
+	reprs := make([]string, len(items))
+	for i, item := range items {
+		reprs[i] = item.Repr()
+	}
+	return v.Type().Name + "([" + strings.Join(reprs, ", ") + "])"
+}
+
+// what the view show right now
+func (v *DictView) Items() []Value {
+	switch v.kind {
+	case "keys":
+		return v.d.Keys()
+	case "values":
+		return v.d.values()
+	}
+	return v.d.items()
+}
+
+func (v *DictView) contains(item Value) (bool, error) {
+	switch v.kind {
+	case "keys":
+		_, ok, err := v.d.Get(item)
+		return ok, err
+	case "items":
+		t, ok := item.(*Tuple)
+		if !ok || len(t.Items) != 2 {
+			return false, nil
+		}
+		value, ok, err := v.d.Get(t.Items[0])
+		return ok && (Is(value, t.Items[1]) || Equal(value, t.Items[1])), err
+	}
+	return containsItem(v.d.values(), item), nil
+}
+
+// keys and items views are equal when they hold the same things, like sets,
+// values views are only equal to themselves
+func (v *DictView) equal(other *DictView) bool {
+	if v == other {
+		return true
+	}
+	if v.kind != other.kind || v.kind == "values" || v.d.count != other.d.count {
+		return false
+	}
+	for _, item := range v.Items() {
+		if ok, _ := other.contains(item); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+/*##############
+### METHODS ###
+##############*/
+
+func init() {
+	DictType.New = newDict
+	DictType.Methods = map[string]MethodFunc{
+		"keys": dictView("keys"), "values": dictView("values"), "items": dictView("items"),
+		"get": dictGet, "pop": dictPop, "setdefault": dictSetdefault, "update": dictUpdate,
+		"clear": dictClear, "copy": dictCopy, "popitem": dictPopitem,
+	}
+}
+
+// dict(), dict(mapping), dict(iterable) and dict(**kwargs)
+func newDict(args []Value, kwargs map[string]Value) (Value, error) {
+	if err := atMostArgs("dict", args, 1); err != nil {
+		return nil, err
+	}
+	d := &Dict{}
+	if err := d.update(args, kwargs); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// add the pairs of a dict or of an iterable of pairs, then the keywords
+func (d *Dict) update(args []Value, kwargs map[string]Value) error {
+	if len(args) == 1 {
+		if other, ok := args[0].(*Dict); ok {
+			for _, e := range other.entries {
+				if e.Key != nil {
+					if err := d.Set(e.Key, e.Value); err != nil {
+						return err
+					}
+				}
+			}
+		} else {
+			items, err := Iterate(args[0])
+			if err != nil {
+				return err
+			}
+			for i, item := range items {
+				pair, err := Iterate(item)
+				if err != nil {
+					return Errorf(TypeError, "cannot convert dictionary update sequence element #%d to a sequence", i)
+				}
+				if len(pair) != 2 {
+					return Errorf(ValueError, "dictionary update sequence element #%d has length %d; 2 is required", i, len(pair))
+				}
+				if err := d.Set(pair[0], pair[1]); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	for name, v := range kwargs {
+		if err := d.Set(Str(name), v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dict.keys(), dict.values() and dict.items()
+func dictView(kind string) MethodFunc {
+	return func(self Value, args []Value, kwargs map[string]Value) (Value, error) {
+		if err := noArgs("dict."+kind, args, kwargs); err != nil {
+			return nil, err
+		}
+		return &DictView{d: self.(*Dict), kind: kind}, nil
+	}
+}
+
+// TypeError unless between min and max positional arguments are given
+func rangeArgs(name string, args []Value, min, max int) error {
+	if len(args) < min {
+		return Errorf(TypeError, "%s expected at least %d argument%s, got %d", name, min, plural(min), len(args))
+	}
+	return atMostArgs(name, args, max)
+}
+
+// dict.get(key, default=None)
+func dictGet(self Value, args []Value, kwargs map[string]Value) (Value, error) {
+	if err := noKeywords("get", kwargs); err != nil {
+		return nil, err
+	}
+	if err := rangeArgs("get", args, 1, 2); err != nil {
+		return nil, err
+	}
+	v, ok, err := self.(*Dict).Get(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return v, nil
+	}
+	if len(args) == 2 {
+		return args[1], nil
+	}
+	return None, nil
+}
+
+// dict.pop(key[, default]), KeyError when the key is missing without default
+func dictPop(self Value, args []Value, kwargs map[string]Value) (Value, error) {
+	if err := noKeywords("pop", kwargs); err != nil {
+		return nil, err
+	}
+	if err := rangeArgs("pop", args, 1, 2); err != nil {
+		return nil, err
+	}
+	v, ok, err := self.(*Dict).Delete(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return v, nil
+	}
+	if len(args) == 2 {
+		return args[1], nil
+	}
+	return nil, &Exception{Class: KeyError, Args: []Value{args[0]}}
+}
+
+// dict.setdefault(key, default=None), the value of key, added when missing
+func dictSetdefault(self Value, args []Value, kwargs map[string]Value) (Value, error) {
+	if err := noKeywords("setdefault", kwargs); err != nil {
+		return nil, err
+	}
+	if err := rangeArgs("setdefault", args, 1, 2); err != nil {
+		return nil, err
+	}
+	d := self.(*Dict)
+	v, ok, err := d.Get(args[0])
+	if err != nil || ok {
+		return v, err
+	}
+	v = None
+	if len(args) == 2 {
+		v = args[1]
+	}
+	return v, d.Set(args[0], v)
+}
+
+// dict.update([other], **kwargs)
+func dictUpdate(self Value, args []Value, kwargs map[string]Value) (Value, error) {
+	if err := atMostArgs("update", args, 1); err != nil {
+		return nil, err
+	}
+	return None, self.(*Dict).update(args, kwargs)
+}
+
+// dict.clear()
+func dictClear(self Value, args []Value, kwargs map[string]Value) (Value, error) {
+	if err := noArgs("dict.clear", args, kwargs); err != nil {
+		return nil, err
+	}
+	*self.(*Dict) = Dict{}
+	return None, nil
+}
+
+// dict.copy(), a shallow copy
+func dictCopy(self Value, args []Value, kwargs map[string]Value) (Value, error) {
+	if err := noArgs("dict.copy", args, kwargs); err != nil {
+		return nil, err
+	}
+	d := &Dict{}
+	if err := d.update([]Value{self}, nil); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// dict.popitem(), remove and return the last added (key, value) pair
+func dictPopitem(self Value, args []Value, kwargs map[string]Value) (Value, error) {
+	if err := noArgs("dict.popitem", args, kwargs); err != nil {
+		return nil, err
+	}
+	d := self.(*Dict)
+	for i := len(d.entries) - 1; i >= 0; i-- {
+		if e := d.entries[i]; e.Key != nil {
+			d.removeAt(i, e.hash)
+			return &Tuple{Items: []Value{e.Key, e.Value}}, nil
+		}
+	}
+	return nil, &Exception{Class: KeyError, Args: []Value{Str("popitem(): dictionary is empty")}}
+}
+
+// hash(object)
+func builtinHash(args []Value, kwargs map[string]Value) (Value, error) {
+	if err := noKeywords("hash", kwargs); err != nil {
+		return nil, err
+	}
+	if err := exactArgs("hash", args, 1); err != nil {
+		return nil, err
+	}
+	h, err := Hash(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return Int(h), nil
+}
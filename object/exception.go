@@ -0,0 +1,326 @@
+package object
+
+import (
+	"fmt"
+	"strings"
+)
+
+// exception classes, Base link each class to its parent
+var (
+	BaseException       = &Type{Name: "BaseException"}
+	ExceptionType       = &Type{Name: "Exception", Base: BaseException}
+	ArithmeticError     = &Type{Name: "ArithmeticError", Base: ExceptionType}
+	ZeroDivisionError   = &Type{Name: "ZeroDivisionError", Base: ArithmeticError}
+	OverflowError       = &Type{Name: "OverflowError", Base: ArithmeticError}
+	AttributeError      = &Type{Name: "AttributeError", Base: ExceptionType}
+	LookupError         = &Type{Name: "LookupError", Base: ExceptionType}
+	IndexError          = &Type{Name: "IndexError", Base: LookupError}
+	KeyError            = &Type{Name: "KeyError", Base: LookupError}
+	NameError           = &Type{Name: "NameError", Base: ExceptionType}
+	UnboundLocalError   = &Type{Name: "UnboundLocalError", Base: NameError}
+	OSError             = &Type{Name: "OSError", Base: ExceptionType}
+	RuntimeError        = &Type{Name: "RuntimeError", Base: ExceptionType}
+	SystemError         = &Type{Name: "SystemError", Base: ExceptionType}
+	RecursionError      = &Type{Name: "RecursionError", Base: RuntimeError}
+	NotImplementedError = &Type{Name: "NotImplementedError", Base: RuntimeError}
+	SyntaxError         = &Type{Name: "SyntaxError", Base: ExceptionType}
+	IndentationError    = &Type{Name: "IndentationError", Base: SyntaxError}
+	TypeError           = &Type{Name: "TypeError", Base: ExceptionType}
+	ValueError          = &Type{Name: "ValueError", Base: ExceptionType}
+	EOFError            = &Type{Name: "EOFError", Base: ExceptionType}
+	KeyboardInterrupt   = &Type{Name: "KeyboardInterrupt", Base: BaseException}
+	MemoryError         = &Type{Name: "MemoryError", Base: ExceptionType}
+	StopIteration       = &Type{Name: "StopIteration", Base: ExceptionType}
+	ImportError         = &Type{Name: "ImportError", Base: ExceptionType}
+	ModuleNotFoundError = &Type{Name: "ModuleNotFoundError", Base: ImportError}
+	FileNotFoundError   = &Type{Name: "FileNotFoundError", Base: OSError}
+	FileExistsError     = &Type{Name: "FileExistsError", Base: OSError}
+	IsADirectoryError   = &Type{Name: "IsADirectoryError", Base: OSError}
+	PermissionError     = &Type{Name: "PermissionError", Base: OSError}
+
+	// io.UnsupportedOperation, not a builtin name
+	UnsupportedOperation = &Type{Name: "UnsupportedOperation", Base: OSError}
+)
+
+// one line of a traceback: the function and the position running in it
+type TracebackEntry struct {
+	Name   string // function name, <module> for module level code
+	Row    int
+	Column int
+}
+
+// raised exception, it is both a Python value and a Go error
+type Exception struct {
+	Class     *Type
+	Args      []Value
+	Row       int              // where a SyntaxError was found, 0 for runtime errors
+	Column    int              //
+	Traceback []TracebackEntry // innermost call first
+
+	Cause           *Exception // set by raise ... from
+	Context         *Exception // exception being handled when this one was raised
+	SuppressContext bool       // true after raise ... from, even from None
+}
+
+// create an exception of class cls with a formatted message
+func Errorf(cls *Type, format string, args ...interface{}) *Exception {
+	return &Exception{Class: cls, Args: []Value{Str(fmt.Sprintf(format, args...))}}
+}
+
+// return err as an exception, other Go errors become a RuntimeError
+func AsException(err error) *Exception {
+	if exc, ok := err.(*Exception); ok {
+		return exc
+	}
+	return Errorf(RuntimeError, "%s", err)
+}
+
+// the value of raise <expr>, an exception class is called without arguments
+func NewException(v Value) (*Exception, error) {
+	switch x := v.(type) {
+	case *Exception:
+		return x, nil
+	case *Type:
+		if IsSubclass(x, BaseException) {
+			return &Exception{Class: x}, nil
+		}
+	}
+	return nil, Errorf(TypeError, "exceptions must derive from BaseException")
+}
+
+// raise exc from cause, cause must be an exception, an exception class or None
+func (e *Exception) SetCause(cause Value) error {
+	e.SuppressContext = true
+	if cause == None {
+		e.Cause = nil
+		return nil
+	}
+	c, err := NewException(cause)
+	if err != nil {
+		return Errorf(TypeError, "exception causes must derive from BaseException")
+	}
+	e.Cause = c
+	return nil
+}
+
+// record handled as the exception being handled when e was raised, unless
+// e already has a context
+func (e *Exception) SetContext(handled *Exception) {
+	if handled == nil || e.Context != nil || handled == e {
+		return
+	}
+	// do not make a loop of contexts
+	for c := handled.Context; c != nil; c = c.Context {
+		if c == e {
+			return
+		}
+	}
+	e.Context = handled
+}
+
+// true if the exception match the class of an except clause, or one of the
+// classes of a tuple. Every class of the tuple is checked before matching
+func (e *Exception) Matches(cls Value) (bool, error) {
+	classes := []Value{cls}
+	if tuple, ok := cls.(*Tuple); ok {
+		classes = tuple.Items
+	}
+	for _, c := range classes {
+		if t, ok := c.(*Type); !ok || !IsSubclass(t, BaseException) {
+			return false, Errorf(TypeError, "catching classes that do not inherit from BaseException is not allowed")
+		}
+	}
+	for _, c := range classes {
+		if IsSubclass(e.Class, c.(*Type)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// record that the exception passed through function name at row and column
+func (e *Exception) AddTraceback(name string, row, column int) {
+	e.Traceback = append(e.Traceback, TracebackEntry{Name: name, Row: row, Column: column})
+}
+
+// e.args, e.__cause__, e.__context__ and e.__suppress_context__, the
+// missing cause and context are None
+func (e *Exception) attr(name string) (Value, bool) {
+	chained := func(c *Exception) Value {
+		if c == nil {
+			return None
+		}
+		return c
+	}
+	switch name {
+	case "args":
+		return &Tuple{Items: append([]Value{}, e.Args...)}, true
+	case "__cause__":
+		return chained(e.Cause), true
+	case "__context__":
+		return chained(e.Context), true
+	case "__suppress_context__":
+		return Bool(e.SuppressContext), true
+	}
+	return nil, false
+}
+
+func (e *Exception) Type() *Type { return e.Class }
+func (e *Exception) Truth() bool { return true }
+
+func (e *Exception) Repr() string {
+	var args []string
+	for _, a := range e.Args {
+		args = append(args, a.Repr())
+	}
+	return e.Class.Name + "(" + strings.Join(args, ", ") + ")"
+}
+
+// str() of an exception, the message when there is one argument, KeyError
+// show the repr of the missing key
+func (e *Exception) Message() string {
+	switch len(e.Args) {
+	case 0:
+		return ""
+	case 1:
+		if IsSubclass(e.Class, KeyError) {
+			return e.Args[0].Repr()
+		}
+		return StrOf(e.Args[0])
+	}
+	var args []string
+	for _, a := range e.Args {
+		args = append(args, a.Repr())
+	}
+	return "(" + strings.Join(args, ", ") + ")"
+}
+
+// last line of a traceback, ex: "ZeroDivisionError: division by zero"
+func (e *Exception) Error() string {
+	if msg := e.Message(); msg != "" {
+		return e.Class.Name + ": " + msg
+	}
+	return e.Class.Name
+}
+
+// true if t is cls or a subclass of cls
+func IsSubclass(t, cls *Type) bool {
+	for ; t != nil; t = t.Base {
+		if t == cls {
+			return true
+		}
+	}
+	return false
+}
+
+// format an uncaught exception like CPython, lines is the source split in
+// lines, the cause or the context come first
+func FormatException(e *Exception, filename string, lines []string) string {
+	var b strings.Builder
+	formatChain(&b, e, filename, lines, map[*Exception]bool{})
+	return b.String()
+}
+
+func formatChain(b *strings.Builder, e *Exception, filename string, lines []string, seen map[*Exception]bool) {
+	seen[e] = true
+	if e.Cause != nil && !seen[e.Cause] {
+		formatChain(b, e.Cause, filename, lines, seen)
+		b.WriteString("\nThe above exception was the direct cause of the following exception:\n\n")
+	} else if e.Context != nil && !e.SuppressContext && !seen[e.Context] {
+		formatChain(b, e.Context, filename, lines, seen)
+		b.WriteString("\nDuring handling of the above exception, another exception occurred:\n\n")
+	}
+	formatOne(b, e, filename, lines)
+}
+
+// traceback and message of one exception
+func formatOne(b *strings.Builder, e *Exception, filename string, lines []string) {
+	sourceLine := func(row int) string {
+		if row >= 1 && row <= len(lines) {
+			return strings.TrimSpace(lines[row-1])
+		}
+		return ""
+	}
+	if len(e.Traceback) > 0 {
+		b.WriteString("Traceback (most recent call last):\n")
+		// like python, the same entry repeated more than 3 times is only
+		// printed 3 times, ex: in a runaway recursion
+		repeated := 0
+		for i := len(e.Traceback) - 1; i >= 0; i-- {
+			entry := e.Traceback[i]
+			if i < len(e.Traceback)-1 && entry == e.Traceback[i+1] {
+				repeated++
+			} else {
+				writeRepeated(b, repeated)
+				repeated = 0
+			}
+			if repeated >= 3 {
+				continue
+			}
+			fmt.Fprintf(b, "  File \"%s\", line %d, in %s\n", filename, entry.Row, entry.Name)
+			if line := sourceLine(entry.Row); line != "" {
+				fmt.Fprintf(b, "    %s\n", line)
+			}
+		}
+		writeRepeated(b, repeated)
+	}
+	if IsSubclass(e.Class, SyntaxError) && e.Row > 0 {
+		fmt.Fprintf(b, "  File \"%s\", line %d\n", filename, e.Row)
+		if e.Row <= len(lines) {
+			line := lines[e.Row-1]
+			trimmed := strings.TrimLeft(line, " \t")
+			caret := e.Column - (len(line) - len(trimmed))
+			if caret < 1 {
+				caret = 1
+			}
+			fmt.Fprintf(b, "    %s\n", strings.TrimRight(trimmed, " \t\r"))
+			fmt.Fprintf(b, "    %s^\n", strings.Repeat(" ", caret-1))
+		}
+	}
+	b.WriteString(e.Error())
+	b.WriteString("\n")
+}
+
+// note replacing the entries not printed by FormatException
+func writeRepeated(b *strings.Builder, repeated int) {
+	if n := repeated - 2; n > 0 {
+		s := "s"
+		if n == 1 {
+			s = ""
+		}
+		fmt.Fprintf(b, "  [Previous line repeated %d more time%s]\n", n, s)
+	}
+}
+
+// 'a', 'a' and 'b', 'a', 'b', and 'c'
+func quoteNames(names []string) string {
+	quoted := make([]string, len(names))
+	for i, n := range names {
+		quoted[i] = "'" + n + "'"
+	}
+	switch len(quoted) {
+	case 1:
+		return quoted[0]
+	case 2:
+		return quoted[0] + " and " + quoted[1]
+	}
+	return strings.Join(quoted[:len(quoted)-1], ", ") + ", and " + quoted[len(quoted)-1]
+}
+
+// call a class: its New function or, for exception classes, a new exception
+func Instantiate(t *Type, args []Value, kwargs map[string]Value) (Value, error) {
+	if t.New != nil {
+		v, err := t.New(args, kwargs)
+		if err != nil {
+			return nil, AsException(err)
+		}
+		return v, nil
+	}
+	if !IsSubclass(t, BaseException) {
+		return nil, Errorf(TypeError, "cannot create '%s' instances", t.Name)
+	}
+	if err := noKeywords(t.Name, kwargs); err != nil {
+		return nil, err
+	}
+	return &Exception{Class: t, Args: append([]Value(nil), args...)}, nil
+}
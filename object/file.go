@@ -0,0 +1,422 @@
+package object
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"syscall"
+	"unicode/utf8"
+)
+
+// text file made by open(), or a standard stream of the sys module. The
+// standard streams read and write the current ones of their Streams
+type File struct {
+	name, mode string
+	reader     func() *bufio.Reader // nil when the file is not readable
+	writer     func() io.Writer     // nil when the file is not writable
+	closer     io.Closer            // nil for the standard streams
+	closed     bool
+}
+
+var FileType = &Type{Name: "TextIOWrapper"}
+
+func (f *File) Type() *Type { return FileType }
+func (f *File) Truth() bool { return true }
+func (f *File) Repr() string {
+	return fmt.Sprintf("<_io.TextIOWrapper name=%s mode=%s encoding='utf-8'>", Str(f.name).Repr(), Str(f.mode).Repr())
+}
+
+// the next line, a file is an iterator over its lines
+func (f *File) Next() (Value, bool, error) {
+	line, err := f.readLine()
+	if err != nil {
+		return nil, false, err
+	}
+	return line, line != "", nil
+}
+
+// ValueError once the file is closed, UnsupportedOperation when it is not
+// readable or writable as the operation need
+func (f *File) check(what string, ok bool) error {
+	if f.closed {
+		return Errorf(ValueError, "I/O operation on closed file.")
+	}
+	if !ok {
+		return Errorf(UnsupportedOperation, "not %s", what)
+	}
+	return nil
+}
+
+// the next line with its newline, "" at the end of the file
+func (f *File) readLine() (Str, error) {
+	if err := f.check("readable", f.reader != nil); err != nil {
+		return "", err
+	}
+	line, err := f.reader().ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", Errorf(OSError, "%s", err)
+	}
+	return Str(line), nil
+}
+
+func (f *File) write(s string) error {
+	if err := f.check("writable", f.writer != nil); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(f.writer(), s); err != nil {
+		return Errorf(OSError, "%s", err)
+	}
+	return nil
+}
+
+// writer that keep some of what is written until Flush
+type flusher interface {
+	Flush() error
+}
+
+func init() {
+	FileType.Methods = map[string]MethodFunc{
+		"write":     fileWrite,
+		"read":      fileRead,
+		"readline":  fileReadline,
+		"readlines": fileReadlines,
+		"flush":     fileFlush,
+		"close":     fileClose,
+	}
+}
+
+// file.write(s), the number of characters written
+func fileWrite(self Value, args []Value, kwargs map[string]Value) (Value, error) {
+	if err := noKeywords("write", kwargs); err != nil {
+		return nil, err
+	}
+	if err := exactArgs("write", args, 1); err != nil {
+		return nil, err
+	}
+	s, ok := args[0].(Str)
+	if !ok {
+		return nil, Errorf(TypeError, "write() argument must be str, not %s", args[0].Type().Name)
+	}
+	if err := self.(*File).write(string(s)); err != nil {
+		return nil, err
+	}
+	return Int(utf8.RuneCountInString(string(s))), nil
+}
+
+// file.read(size=-1), at most size characters, all of them when size is
+// negative or None
+func fileRead(self Value, args []Value, kwargs map[string]Value) (Value, error) {
+	if err := noKeywords("read", kwargs); err != nil {
+		return nil, err
+	}
+	if err := atMostArgs("read", args, 1); err != nil {
+		return nil, err
+	}
+	size := -1
+	if len(args) == 1 && args[0] != None {
+		var err error
+		if size, err = intArg(args[0]); err != nil {
+			return nil, err
+		}
+	}
+	f := self.(*File)
+	if err := f.check("readable", f.reader != nil); err != nil {
+		return nil, err
+	}
+	r := f.reader()
+	if size < 0 {
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return nil, Errorf(OSError, "%s", err)
+		}
+		return Str(b), nil
+	}
+	var b strings.Builder
+	for ; size > 0; size-- {
+		c, _, err := r.ReadRune()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, Errorf(OSError, "%s", err)
+		}
+		b.WriteRune(c)
+	}
+	return Str(b.String()), nil
+}
+
+// file.readline(), the next line with its newline, empty at the end
+func fileReadline(self Value, args []Value, kwargs map[string]Value) (Value, error) {
+	if err := noArgs("readline", args, kwargs); err != nil {
+		return nil, err
+	}
+	return self.(*File).readLine()
+}
+
+// file.readlines(), a list of the lines left
+func fileReadlines(self Value, args []Value, kwargs map[string]Value) (Value, error) {
+	if err := noArgs("readlines", args, kwargs); err != nil {
+		return nil, err
+	}
+	lines := &List{}
+	for {
+		line, err := self.(*File).readLine()
+		if err != nil {
+			return nil, err
+		}
+		if line == "" {
+			return lines, nil
+		}
+		lines.Items = append(lines.Items, line)
+	}
+}
+
+// file.flush()
+func fileFlush(self Value, args []Value, kwargs map[string]Value) (Value, error) {
+	if err := noArgs("flush", args, kwargs); err != nil {
+		return nil, err
+	}
+	f := self.(*File)
+	if err := f.check("", true); err != nil {
+		return nil, err
+	}
+	if f.writer != nil {
+		if w, ok := f.writer().(flusher); ok {
+			if err := w.Flush(); err != nil {
+				return nil, Errorf(OSError, "%s", err)
+			}
+		}
+	}
+	return None, nil
+}
+
+// file.close(), closing again does nothing
+func fileClose(self Value, args []Value, kwargs map[string]Value) (Value, error) {
+	if err := noArgs("close", args, kwargs); err != nil {
+		return nil, err
+	}
+	f := self.(*File)
+	if f.closed {
+		return None, nil
+	}
+	f.closed = true
+	if f.closer != nil {
+		if err := f.closer.Close(); err != nil {
+			return nil, Errorf(OSError, "%s", err)
+		}
+	}
+	return None, nil
+}
+
+// open(file, mode='r', encoding=None), the modes are r, w, a and x for
+// text files, the encoding is always UTF-8
+func builtinOpen(args []Value, kwargs map[string]Value) (Value, error) {
+	if err := atMostArgs("open", args, 3); err != nil {
+		return nil, err
+	}
+	params := []string{"file", "mode", "encoding"}
+	values := make([]Value, len(params))
+	copy(values, args)
+	for name, v := range kwargs {
+		i := 0
+		for i < len(params) && params[i] != name {
+			i++
+		}
+		if i == len(params) {
+			return nil, Errorf(TypeError, "'%s' is an invalid keyword argument for open()", name)
+		}
+		if values[i] != nil {
+			return nil, Errorf(TypeError, "argument for open() given by name ('%s') and position (%d)", name, i+1)
+		}
+		values[i] = v
+	}
+	file, mode, encoding := values[0], values[1], values[2]
+	if file == nil {
+		return nil, Errorf(TypeError, "open() missing required argument 'file' (pos 1)")
+	}
+	name, ok := file.(Str)
+	if !ok {
+		return nil, Errorf(TypeError, "expected str, bytes or os.PathLike object, not %s", file.Type().Name)
+	}
+	if mode == nil {
+		mode = Str("r")
+	}
+	m, ok := mode.(Str)
+	if !ok {
+		return nil, Errorf(TypeError, "open() argument 'mode' must be str, not %s", mode.Type().Name)
+	}
+	if encoding != nil && encoding != None {
+		if _, ok := encoding.(Str); !ok {
+			return nil, Errorf(TypeError, "open() argument 'encoding' must be str or None, not %s", encoding.Type().Name)
+		}
+	}
+
+	flags := map[string]int{
+		"r": os.O_RDONLY,
+		"w": os.O_WRONLY | os.O_CREATE | os.O_TRUNC,
+		"a": os.O_WRONLY | os.O_CREATE | os.O_APPEND,
+		"x": os.O_WRONLY | os.O_CREATE | os.O_EXCL,
+	}
+	kind := strings.Replace(string(m), "t", "", 1)
+	flag, ok := flags[kind]
+	if !ok {
+		return nil, Errorf(ValueError, "invalid mode: %s", m.Repr())
+	}
+	osFile, err := os.OpenFile(string(name), flag, 0666)
+	if err != nil {
+		return nil, osError(err, string(name))
+	}
+	if info, err := osFile.Stat(); err == nil && info.IsDir() {
+		osFile.Close()
+		return nil, osError(syscall.EISDIR, string(name))
+	}
+
+	f := &File{name: string(name), mode: string(m), closer: osFile}
+	if kind == "r" {
+		r := bufio.NewReader(osFile)
+		f.reader = func() *bufio.Reader { return r }
+	} else {
+		f.writer = func() io.Writer { return osFile }
+	}
+	return f, nil
+}
+
+// OSError of a failed file operation, the subclass and the message with
+// the error number and the file name are the ones of CPython
+func osError(err error, name string) *Exception {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return Errorf(OSError, "%s", err)
+	}
+	cls := OSError
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		cls = FileNotFoundError
+	case errors.Is(err, fs.ErrExist):
+		cls = FileExistsError
+	case errors.Is(err, fs.ErrPermission):
+		cls = PermissionError
+	case errno == syscall.EISDIR:
+		cls = IsADirectoryError
+	}
+	msg := errno.Error()
+	if msg != "" {
+		msg = strings.ToUpper(msg[:1]) + msg[1:]
+	}
+	return Errorf(cls, "[Errno %d] %s: %s", int(errno), msg, Str(name).Repr())
+}
+
+// print(*objects, sep=' ', end='\n', file=None, flush=False), file is any
+// value with a write method, None is sys.stdout once sys is imported and the
+// standard output before
+func (s *Streams) print(args []Value, kwargs map[string]Value) (Value, error) {
+	sep, end := " ", "\n"
+	var file Value = None
+	flush := false
+	for name, v := range kwargs {
+		switch name {
+		case "sep", "end":
+			if v == None {
+				continue
+			}
+			text, ok := v.(Str)
+			if !ok {
+				return nil, Errorf(TypeError, "%s must be None or a string, not %s", name, v.Type().Name)
+			}
+			if name == "sep" {
+				sep = string(text)
+			} else {
+				end = string(text)
+			}
+		case "file":
+			file = v
+		case "flush":
+			flush = v.Truth()
+		default:
+			return nil, Errorf(TypeError, "'%s' is an invalid keyword argument for print()", name)
+		}
+	}
+
+	if file == None && s.sys != nil {
+		var ok bool
+		if file, ok = s.sys.Attrs["stdout"]; !ok {
+			return nil, Errorf(RuntimeError, "lost sys.stdout")
+		}
+		if file == None {
+			return None, nil
+		}
+	}
+
+	// files get the whole text in a single write
+	f, isFile := file.(*File)
+	if file == None || isFile {
+		var b strings.Builder
+		for i, v := range args {
+			if i > 0 {
+				b.WriteString(sep)
+			}
+			b.WriteString(StrOf(v))
+		}
+		b.WriteString(end)
+		if isFile {
+			if err := f.write(b.String()); err != nil {
+				return nil, err
+			}
+			if flush {
+				return fileFlush(f, nil, nil)
+			}
+			return None, nil
+		}
+		io.WriteString(s.Stdout, b.String())
+		if w, ok := s.Stdout.(flusher); ok && flush {
+			w.Flush()
+		}
+		return None, nil
+	}
+
+	// each part is written by its own call, like CPython
+	write, err := callableAttr(file, "write")
+	if err != nil {
+		return nil, err
+	}
+	for i, v := range args {
+		if i > 0 {
+			if _, err := write.Call([]Value{Str(sep)}, nil); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := write.Call([]Value{Str(StrOf(v))}, nil); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := write.Call([]Value{Str(end)}, nil); err != nil {
+		return nil, err
+	}
+	if flush {
+		fn, err := callableAttr(file, "flush")
+		if err != nil {
+			return nil, err
+		}
+		if _, err := fn.Call(nil, nil); err != nil {
+			return nil, err
+		}
+	}
+	return None, nil
+}
+
+// v.name that must be callable, ex: the write method of a file
+func callableAttr(v Value, name string) (Callable, error) {
+	attr, err := GetAttr(v, name)
+	if err != nil {
+		return nil, err
+	}
+	fn, ok := attr.(Callable)
+	if !ok {
+		return nil, Errorf(TypeError, "'%s' object is not callable", attr.Type().Name)
+	}
+	return fn, nil
+}
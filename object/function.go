@@ -0,0 +1,251 @@
+package object
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// function defined in Python by one of the engines
+type PyFunction interface {
+	Callable
+	FuncName() string
+}
+
+// parameters of a function. The locals of a call start with the values of
+// Names, followed by the tuple of *args and the dict of **kwargs when the
+// function has them
+type Params struct {
+	Names    []string // positional parameters then keyword-only ones
+	PosOnly  int      // positional-only parameters at the start of Names
+	Argcount int      // positional parameters, the others are keyword-only
+	VarArg   string   // name of the *args parameter, "" when there is none
+	KwArg    string   // name of the **kwargs parameter, "" when there is none
+}
+
+// names of the locals bound by a call, in order
+func (p *Params) Locals() []string {
+	names := p.Names
+	if p.VarArg != "" || p.KwArg != "" {
+		names = append([]string(nil), p.Names...)
+	}
+	if p.VarArg != "" {
+		names = append(names, p.VarArg)
+	}
+	if p.KwArg != "" {
+		names = append(names, p.KwArg)
+	}
+	return names
+}
+
+// values of the locals of a call to the function name, in the order of
+// Locals. The last len(kwnames) args are the keyword arguments, defaults are
+// the values of the last positional parameters and kwDefaults the ones of
+// the keyword-only parameters. The TypeErrors are the ones of CPython
+func (p *Params) Bind(name string, args []Value, kwnames []string, defaults []Value, kwDefaults map[string]Value) ([]Value, *Exception) {
+	nargs := len(args) - len(kwnames)
+	simple := len(p.Names) == p.Argcount && p.VarArg == "" && p.KwArg == ""
+	if simple && nargs == p.Argcount && len(kwnames) == 0 {
+		return args, nil
+	}
+
+	values := make([]Value, len(p.Names), len(p.Names)+2)
+	n := nargs
+	if n > p.Argcount {
+		n = p.Argcount
+	}
+	copy(values, args[:n])
+	if p.VarArg != "" {
+		values = append(values, &Tuple{Items: append([]Value(nil), args[n:nargs]...)})
+	}
+	var kwdict *Dict
+	if p.KwArg != "" {
+		kwdict = &Dict{}
+		values = append(values, kwdict)
+	}
+
+	for i, kw := range kwnames {
+		v := args[nargs+i]
+		j := p.PosOnly
+		for j < len(p.Names) && p.Names[j] != kw {
+			j++
+		}
+		if j < len(p.Names) {
+			if values[j] != nil {
+				return nil, Errorf(TypeError, "%s() got multiple values for argument '%s'", name, kw)
+			}
+			values[j] = v
+			continue
+		}
+		if kwdict != nil {
+			kwdict.Set(Str(kw), v)
+			continue
+		}
+		var posOnly []string
+		for _, kw := range kwnames {
+			for _, param := range p.Names[:p.PosOnly] {
+				if kw == param {
+					posOnly = append(posOnly, kw)
+				}
+			}
+		}
+		if len(posOnly) > 0 {
+			return nil, Errorf(TypeError, "%s() got some positional-only arguments passed as keyword arguments: '%s'",
+				name, strings.Join(posOnly, ", "))
+		}
+		return nil, Errorf(TypeError, "%s() got an unexpected keyword argument '%s'", name, kw)
+	}
+
+	if nargs > p.Argcount && p.VarArg == "" {
+		return nil, p.tooMany(name, nargs, len(defaults), values)
+	}
+	firstDefault := p.Argcount - len(defaults)
+	var missing []string
+	for i := n; i < p.Argcount; i++ {
+		if values[i] == nil && i < firstDefault {
+			missing = append(missing, p.Names[i])
+		}
+	}
+	if len(missing) > 0 {
+		return nil, Errorf(TypeError, "%s() missing %d required positional argument%s: %s",
+			name, len(missing), plural(len(missing)), quoteNames(missing))
+	}
+	for i := firstDefault; i < p.Argcount; i++ {
+		if values[i] == nil {
+			values[i] = defaults[i-firstDefault]
+		}
+	}
+	for i := p.Argcount; i < len(p.Names); i++ {
+		if values[i] != nil {
+			continue
+		}
+		if v, ok := kwDefaults[p.Names[i]]; ok {
+			values[i] = v
+		} else {
+			missing = append(missing, p.Names[i])
+		}
+	}
+	if len(missing) > 0 {
+		return nil, Errorf(TypeError, "%s() missing %d required keyword-only argument%s: %s",
+			name, len(missing), plural(len(missing)), quoteNames(missing))
+	}
+	return values, nil
+}
+
+// TypeError for given positional arguments when the function has no *args,
+// the keyword-only arguments already bound in values are counted too
+func (p *Params) tooMany(name string, given, ndefaults int, values []Value) *Exception {
+	takes := fmt.Sprintf("%d positional argument%s", p.Argcount, plural(p.Argcount))
+	if ndefaults > 0 {
+		takes = fmt.Sprintf("from %d to %d positional arguments", p.Argcount-ndefaults, p.Argcount)
+	}
+	kwonly := 0
+	for _, v := range values[p.Argcount:len(p.Names)] {
+		if v != nil {
+			kwonly++
+		}
+	}
+	if kwonly > 0 {
+		return Errorf(TypeError, "%s() takes %s but %d positional argument%s (and %d keyword-only argument%s) were given",
+			name, takes, given, plural(given), kwonly, plural(kwonly))
+	}
+	was := "were"
+	if given == 1 {
+		was = "was"
+	}
+	return Errorf(TypeError, "%s() takes %s but %d %s given", name, takes, given, was)
+}
+
+// name of a callable in the messages about its arguments, ex: print(). The
+// Python functions are all defined in the __main__ module
+func FunctionStr(v Value) string {
+	switch f := v.(type) {
+	case *Builtin:
+		return f.Name + "()"
+	case *Type:
+		return f.Name + "()"
+	case *Method:
+		return f.Self.Type().Name + "." + f.Name + "()"
+	case PyFunction:
+		return "__main__." + f.FuncName() + "()"
+	}
+	return StrOf(v)
+}
+
+// the positional arguments given by *v in a call to fn, when it is the only
+// positional argument. The items are a copy that can be appended to
+func StarArgs(fn, v Value) ([]Value, error) {
+	items, err := Iterate(v)
+	if err != nil {
+		return nil, Errorf(TypeError, "%s argument after * must be an iterable, not %s", FunctionStr(fn), v.Type().Name)
+	}
+	return items, nil
+}
+
+// add the items of the mapping given by **mapping in a call to fn to the
+// keyword arguments before it
+func MergeKwargs(fn Value, kwargs *Dict, mapping Value) error {
+	m, ok := mapping.(*Dict)
+	if !ok {
+		return Errorf(TypeError, "%s argument after ** must be a mapping, not %s", FunctionStr(fn), mapping.Type().Name)
+	}
+	for _, e := range m.entries {
+		if e.Key == nil {
+			continue
+		}
+		if _, found, _ := kwargs.Get(e.Key); found {
+			return Errorf(TypeError, "%s got multiple values for keyword argument '%s'", FunctionStr(fn), StrOf(e.Key))
+		}
+		kwargs.Set(e.Key, e.Value)
+	}
+	return nil
+}
+
+// the names and the values of the keyword arguments of a call in a dict
+func KeywordArgs(kwargs *Dict) ([]string, []Value, error) {
+	names := make([]string, 0, kwargs.count)
+	values := make([]Value, 0, kwargs.count)
+	for _, e := range kwargs.entries {
+		if e.Key == nil {
+			continue
+		}
+		name, ok := e.Key.(Str)
+		if !ok {
+			return nil, nil, Errorf(TypeError, "keywords must be strings")
+		}
+		names = append(names, string(name))
+		values = append(values, e.Value)
+	}
+	return names, values, nil
+}
+
+// the keyword arguments of a call in the map given to builtins, nil when
+// there are none
+func KeywordMap(kwnames []string, values []Value) map[string]Value {
+	if len(kwnames) == 0 {
+		return nil
+	}
+	kwargs := make(map[string]Value, len(kwnames))
+	for i, name := range kwnames {
+		kwargs[name] = values[i]
+	}
+	return kwargs
+}
+
+// the keyword arguments a builtin give when it calls a function, sorted by
+// name and appended to args
+func SortedKeywords(args []Value, kwargs map[string]Value) ([]Value, []string) {
+	if len(kwargs) == 0 {
+		return args, nil
+	}
+	kwnames := make([]string, 0, len(kwargs))
+	for name := range kwargs {
+		kwnames = append(kwnames, name)
+	}
+	sort.Strings(kwnames)
+	args = append([]Value(nil), args...)
+	for _, name := range kwnames {
+		args = append(args, kwargs[name])
+	}
+	return args, kwnames
+}
@@ -0,0 +1,211 @@
+package object
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// value that produce the items of an iterable one at a time, for loops and
+// the functions taking an iterable get them through Iter
+type Iterator interface {
+	Value
+	Next() (Value, bool, error) // the next item, false once there are no more
+}
+
+// iterator of a builtin type, next compute the items
+type iterator struct {
+	typ  *Type
+	next func() (Value, bool, error) // nil once exhausted, an exhausted iterator stay exhausted
+}
+
+var (
+	ListIteratorType      = &Type{Name: "list_iterator"}
+	TupleIteratorType     = &Type{Name: "tuple_iterator"}
+	StrIteratorType       = &Type{Name: "str_iterator"}
+	StrASCIIIteratorType  = &Type{Name: "str_ascii_iterator"}
+	DictKeyIteratorType   = &Type{Name: "dict_keyiterator"}
+	DictValueIteratorType = &Type{Name: "dict_valueiterator"}
+	DictItemIteratorType  = &Type{Name: "dict_itemiterator"}
+	SetIteratorType       = &Type{Name: "set_iterator"}
+	RangeIteratorType     = &Type{Name: "range_iterator"}
+	CallableIteratorType  = &Type{Name: "callable_iterator"}
+)
+
+func (it *iterator) Type() *Type  { return it.typ }
+func (it *iterator) Truth() bool  { return true }
+func (it *iterator) Repr() string { return fmt.Sprintf("<%s object at %p>", it.typ.Name, it) }
+
+func (it *iterator) Next() (Value, bool, error) {
+	if it.next == nil {
+		return nil, false, nil
+	}
+	v, ok, err := it.next()
+	if !ok && err == nil {
+		it.next = nil
+	}
+	return v, ok, err
+}
+
+// iter(v): an iterator over the items of v, an iterator is its own iterator
+func Iter(v Value) (Iterator, error) {
+	switch x := v.(type) {
+	case Iterator:
+		return x, nil
+	case *List:
+		i := 0
+		return &iterator{typ: ListIteratorType, next: func() (Value, bool, error) {
+			// the list can change while it is iterated, its length is read every time
+			if i >= len(x.Items) {
+				return nil, false, nil
+			}
+			i++
+			return x.Items[i-1], true, nil
+		}}, nil
+	case *Tuple:
+		return sliceIterator(TupleIteratorType, x.Items), nil
+	case Str:
+		return strIterator(x), nil
+	case *Dict:
+		return dictIterator(x, "keys"), nil
+	case *DictView:
+		return dictIterator(x.d, x.kind), nil
+	case *Set:
+		return setIterator(x), nil
+	case *Range:
+		return x.iter(), nil
+	}
+	return nil, Errorf(TypeError, "'%s' object is not iterable", v.Type().Name)
+}
+
+func sliceIterator(typ *Type, items []Value) Iterator {
+	i := 0
+	return &iterator{typ: typ, next: func() (Value, bool, error) {
+		if i >= len(items) {
+			return nil, false, nil
+		}
+		i++
+		return items[i-1], true, nil
+	}}
+}
+
+// the characters of s, the iterator type tell apart ASCII strings like CPython
+func strIterator(s Str) Iterator {
+	typ := StrASCIIIteratorType
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			typ = StrIteratorType
+			break
+		}
+	}
+	offset := 0
+	return &iterator{typ: typ, next: func() (Value, bool, error) {
+		if offset >= len(s) {
+			return nil, false, nil
+		}
+		_, size := utf8.DecodeRuneInString(string(s[offset:]))
+		offset += size
+		return s[offset-size : offset], true, nil
+	}}
+}
+
+// the keys, values or items of d in insertion order, kind is the one of a
+// DictView. The dict must keep its size while it is iterated
+func dictIterator(d *Dict, kind string) Iterator {
+	typ := DictKeyIteratorType
+	switch kind {
+	case "values":
+		typ = DictValueIteratorType
+	case "items":
+		typ = DictItemIteratorType
+	}
+	i, count := 0, d.count
+	return &iterator{typ: typ, next: func() (Value, bool, error) {
+		if d.count != count {
+			count = -1 // stay broken, like CPython
+			return nil, false, Errorf(RuntimeError, "dictionary changed size during iteration")
+		}
+		for ; i < len(d.entries); i++ {
+			if e := d.entries[i]; e.Key != nil {
+				i++
+				switch kind {
+				case "values":
+					return e.Value, true, nil
+				case "items":
+					return &Tuple{Items: []Value{e.Key, e.Value}}, true, nil
+				}
+				return e.Key, true, nil
+			}
+		}
+		return nil, false, nil
+	}}
+}
+
+// the items of s in table order, the set must keep its size while it is iterated
+func setIterator(s *Set) Iterator {
+	i, used := 0, s.used
+	return &iterator{typ: SetIteratorType, next: func() (Value, bool, error) {
+		if s.used != used {
+			used = -1
+			return nil, false, Errorf(RuntimeError, "Set changed size during iteration")
+		}
+		for ; i < len(s.table); i++ {
+			if key := s.table[i].key; key != nil {
+				i++
+				return key, true, nil
+			}
+		}
+		return nil, false, nil
+	}}
+}
+
+// iter(object[, sentinel]), with a sentinel object is called until it
+// return the sentinel
+func builtinIter(args []Value, kwargs map[string]Value) (Value, error) {
+	if err := noKeywords("iter", kwargs); err != nil {
+		return nil, err
+	}
+	if err := rangeArgs("iter", args, 1, 2); err != nil {
+		return nil, err
+	}
+	if len(args) == 1 {
+		return Iter(args[0])
+	}
+	fn, ok := args[0].(Callable)
+	if !ok {
+		return nil, Errorf(TypeError, "iter(v, w): v must be callable")
+	}
+	sentinel := args[1]
+	return &iterator{typ: CallableIteratorType, next: func() (Value, bool, error) {
+		v, err := fn.Call(nil, nil)
+		if err != nil {
+			return nil, false, err
+		}
+		if Is(v, sentinel) || Equal(v, sentinel) {
+			return nil, false, nil
+		}
+		return v, true, nil
+	}}, nil
+}
+
+// next(iterator[, default]), StopIteration or the default once the
+// iterator is exhausted
+func builtinNext(args []Value, kwargs map[string]Value) (Value, error) {
+	if err := noKeywords("next", kwargs); err != nil {
+		return nil, err
+	}
+	if err := rangeArgs("next", args, 1, 2); err != nil {
+		return nil, err
+	}
+	it, ok := args[0].(Iterator)
+	if !ok {
+		return nil, Errorf(TypeError, "'%s' object is not an iterator", args[0].Type().Name)
+	}
+	v, ok, err := it.Next()
+	if err != nil || ok {
+		return v, err
+	}
+	if len(args) == 2 {
+		return args[1], nil
+	}
+	return nil, &Exception{Class: StopIteration}
+}
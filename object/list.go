@@ -0,0 +1,578 @@
+package object
+
+import (
+	"sort"
+	"strings"
+	. "test1/tokenizer"
+)
+
+// mutable sequence, every name bound to the same list see its changes
+type List struct {
+	Items []Value
+
+	inRepr bool // set while Repr run, a list containing itself print as [...]
+}
+
+var (
+	ListType  = &Type{Name: "list"}
+	SliceType = &Type{Name: "slice"}
+)
+
+func (l *List) Type() *Type { return ListType }
+func (l *List) Truth() bool { return len(l.Items) > 0 }
+func (l *List) Len() int    { return len(l.Items) }
+
+func (l *List) Repr() string {
+	if l.inRepr {
+		return "[...]"
+	}
+	l.inRepr = true
+	defer func() { l.inRepr = false }()
+	reprs := make([]string, len(l.Items))
+	for i, v := range l.Items {
+		reprs[i] = v.Repr()
+	}
+	return "[" + strings.Join(reprs, ", ") + "]"
+}
+
+// x[start:stop:step], the missing parts are None
+type Slice struct {
+	Start, Stop, Step Value
+}
+
+func (s *Slice) Type() *Type { return SliceType }
+func (s *Slice) Truth() bool { return true }
+func (s *Slice) Repr() string {
+	return "slice(" + s.Start.Repr() + ", " + s.Stop.Repr() + ", " + s.Step.Repr() + ")"
+}
+
+// start, step and number of items the slice select in a sequence of length n
+func (s *Slice) Indices(n int) (start, step, count int, err error) {
+	start, stop, step, err := s.bounds(n)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if step > 0 && start < stop {
+		count = (stop-start-1)/step + 1
+	} else if step < 0 && stop < start {
+		count = (start-stop-1)/(-step) + 1
+	}
+	return start, step, count, nil
+}
+
+// start, stop and step of the slice clamped to a sequence of length n
+func (s *Slice) bounds(n int) (start, stop, step int, err error) {
+	step = 1
+	if s.Step != None {
+		if step, err = sliceIndex(s.Step); err != nil {
+			return
+		}
+		if step == 0 {
+			return 0, 0, 0, Errorf(ValueError, "slice step cannot be zero")
+		}
+	}
+	// defaults and clamping as in CPython, -1 is before the first item when going backward
+	lower, upper := 0, n
+	if step < 0 {
+		lower, upper = -1, n-1
+	}
+	bound := func(v Value, deflt int) (int, error) {
+		if v == None {
+			return deflt, nil
+		}
+		i, err := sliceIndex(v)
+		if err != nil {
+			return 0, err
+		}
+		if i < 0 {
+			i += n
+			if i < 0 {
+				i = lower
+			}
+		} else if i > upper {
+			i = upper
+		}
+		return i, nil
+	}
+	startDefault, stopDefault := lower, upper
+	if step < 0 {
+		startDefault, stopDefault = upper, lower
+	}
+	if start, err = bound(s.Start, startDefault); err != nil {
+		return
+	}
+	if stop, err = bound(s.Stop, stopDefault); err != nil {
+		return
+	}
+	return start, stop, step, nil
+}
+
+func sliceIndex(v Value) (int, error) {
+	i, ok := clampInt(v)
+	if !ok {
+		return 0, Errorf(TypeError, "slice indices must be integers or None or have an __index__ method")
+	}
+	return int(i), nil
+}
+
+// index into a sequence of length n, negative indexes count from the end,
+// -1 when it is out of range
+func seqIndex(key Value, n int) int {
+	i, _ := clampInt(key)
+	if i < 0 {
+		i += int64(n)
+	}
+	if i < 0 || i >= int64(n) {
+		return -1
+	}
+	return int(i)
+}
+
+// the int value of an argument that must be an integer, ex: the index of pop
+func intArg(v Value) (int, error) {
+	if _, ok := v.(*BigInt); ok {
+		return 0, Errorf(OverflowError, "Python int too large to convert to C ssize_t")
+	}
+	i, ok := toInt(v)
+	if !ok {
+		return 0, Errorf(TypeError, "'%s' object cannot be interpreted as an integer", v.Type().Name)
+	}
+	return int(i), nil
+}
+
+func (l *List) GetItem(key Value) (Value, error) {
+	if s, ok := key.(*Slice); ok {
+		start, step, count, err := s.Indices(len(l.Items))
+		if err != nil {
+			return nil, err
+		}
+		items := make([]Value, count)
+		for i := range items {
+			items[i] = l.Items[start+i*step]
+		}
+		return &List{Items: items}, nil
+	}
+	if !isInt(key) {
+		return nil, Errorf(TypeError, "list indices must be integers or slices, not %s", key.Type().Name)
+	}
+	i := seqIndex(key, len(l.Items))
+	if i < 0 {
+		return nil, Errorf(IndexError, "list index out of range")
+	}
+	return l.Items[i], nil
+}
+
+func (l *List) SetItem(key, v Value) error {
+	if s, ok := key.(*Slice); ok {
+		return l.setSlice(s, v)
+	}
+	if !isInt(key) {
+		return Errorf(TypeError, "list indices must be integers or slices, not %s", key.Type().Name)
+	}
+	i := seqIndex(key, len(l.Items))
+	if i < 0 {
+		return Errorf(IndexError, "list assignment index out of range")
+	}
+	l.Items[i] = v
+	return nil
+}
+
+// a[i:j] = v replace the items, a[i:j:k] = v need as many items as the slice
+func (l *List) setSlice(s *Slice, v Value) error {
+	start, step, count, err := s.Indices(len(l.Items))
+	if err != nil {
+		return err
+	}
+	items, err := Iterate(v)
+	if err != nil {
+		if step == 1 {
+			return Errorf(TypeError, "can only assign an iterable")
+		}
+		return Errorf(TypeError, "must assign iterable to extended slice")
+	}
+	if step == 1 {
+		stop := start + count
+		rest := append([]Value(nil), l.Items[stop:]...)
+		l.Items = append(append(l.Items[:start], items...), rest...)
+		return nil
+	}
+	if len(items) != count {
+		return Errorf(ValueError, "attempt to assign sequence of size %d to extended slice of size %d", len(items), count)
+	}
+	for i, item := range items {
+		l.Items[start+i*step] = item
+	}
+	return nil
+}
+
+func (l *List) DelItem(key Value) error {
+	if s, ok := key.(*Slice); ok {
+		start, step, count, err := s.Indices(len(l.Items))
+		if err != nil {
+			return err
+		}
+		removed := make(map[int]bool, count)
+		for i := 0; i < count; i++ {
+			removed[start+i*step] = true
+		}
+		kept := l.Items[:0]
+		for i, item := range l.Items {
+			if !removed[i] {
+				kept = append(kept, item)
+			}
+		}
+		l.Items = kept
+		return nil
+	}
+	if !isInt(key) {
+		return Errorf(TypeError, "list indices must be integers or slices, not %s", key.Type().Name)
+	}
+	i := seqIndex(key, len(l.Items))
+	if i < 0 {
+		return Errorf(IndexError, "list assignment index out of range")
+	}
+	l.Items = append(l.Items[:i], l.Items[i+1:]...)
+	return nil
+}
+
+// list(iterable=())
+func newList(args []Value, kwargs map[string]Value) (Value, error) {
+	if err := noKeywords("list", kwargs); err != nil {
+		return nil, err
+	}
+	if err := atMostArgs("list", args, 1); err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		return &List{}, nil
+	}
+	items, err := Iterate(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return &List{Items: items}, nil
+}
+
+// largest allocation the Go runtime can make on 64-bit platforms. A
+// repetition needing more raises MemoryError, like a failed allocation in
+// CPython, instead of a panic of the runtime
+const maxAlloc = 1 << 47
+
+// size in bytes of a Value in a slice
+const valueSize = 16
+
+// new list holding the items of l n times, n <= 0 give an empty list
+func (l *List) repeat(n int64) (*List, error) {
+	if n <= 0 || len(l.Items) == 0 {
+		return &List{}, nil
+	}
+	if n > maxAlloc/valueSize/int64(len(l.Items)) {
+		return nil, &Exception{Class: MemoryError}
+	}
+	items := make([]Value, 0, len(l.Items)*int(n))
+	for i := int64(0); i < n; i++ {
+		items = append(items, l.Items...)
+	}
+	return &List{Items: items}, nil
+}
+
+/*################
+### METHODS ###
+################*/
+
+func init() {
+	ListType.New = newList
+	ListType.Methods = map[string]MethodFunc{
+		"append": listAppend, "extend": listExtend, "pop": listPop, "insert": listInsert,
+		"remove": listRemove, "sort": listSort, "reverse": listReverse, "index": listIndex,
+		"count": listCount, "copy": listCopy, "clear": listClear,
+	}
+}
+
+// list.append(x)
+func listAppend(self Value, args []Value, kwargs map[string]Value) (Value, error) {
+	if err := noKeywords("list.append", kwargs); err != nil {
+		return nil, err
+	}
+	if err := exactArgs("list.append", args, 1); err != nil {
+		return nil, err
+	}
+	l := self.(*List)
+	l.Items = append(l.Items, args[0])
+	return None, nil
+}
+
+// list.extend(iterable)
+func listExtend(self Value, args []Value, kwargs map[string]Value) (Value, error) {
+	if err := noKeywords("list.extend", kwargs); err != nil {
+		return nil, err
+	}
+	if err := exactArgs("list.extend", args, 1); err != nil {
+		return nil, err
+	}
+	items, err := Iterate(args[0])
+	if err != nil {
+		return nil, err
+	}
+	l := self.(*List)
+	l.Items = append(l.Items, items...)
+	return None, nil
+}
+
+// list.pop(index=-1)
+func listPop(self Value, args []Value, kwargs map[string]Value) (Value, error) {
+	if err := noKeywords("pop", kwargs); err != nil {
+		return nil, err
+	}
+	if err := atMostArgs("pop", args, 1); err != nil {
+		return nil, err
+	}
+	l := self.(*List)
+	i := len(l.Items) - 1
+	if len(args) == 1 {
+		n, err := intArg(args[0])
+		if err != nil {
+			return nil, err
+		}
+		i = seqIndex(Int(n), len(l.Items))
+	}
+	if len(l.Items) == 0 {
+		return nil, Errorf(IndexError, "pop from empty list")
+	}
+	if i < 0 {
+		return nil, Errorf(IndexError, "pop index out of range")
+	}
+	v := l.Items[i]
+	l.Items = append(l.Items[:i], l.Items[i+1:]...)
+	return v, nil
+}
+
+// list.insert(index, object), the index is clamped to the list
+func listInsert(self Value, args []Value, kwargs map[string]Value) (Value, error) {
+	if err := noKeywords("insert", kwargs); err != nil {
+		return nil, err
+	}
+	if err := exactArgs("insert", args, 2); err != nil {
+		return nil, err
+	}
+	l := self.(*List)
+	i, err := intArg(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if i < 0 {
+		i += len(l.Items)
+		if i < 0 {
+			i = 0
+		}
+	} else if i > len(l.Items) {
+		i = len(l.Items)
+	}
+	l.Items = append(l.Items, nil)
+	copy(l.Items[i+1:], l.Items[i:])
+	l.Items[i] = args[1]
+	return None, nil
+}
+
+// list.remove(value), the first item equal to value
+func listRemove(self Value, args []Value, kwargs map[string]Value) (Value, error) {
+	if err := noKeywords("list.remove", kwargs); err != nil {
+		return nil, err
+	}
+	if err := exactArgs("list.remove", args, 1); err != nil {
+		return nil, err
+	}
+	l := self.(*List)
+	for i, item := range l.Items {
+		if Is(item, args[0]) || Equal(item, args[0]) {
+			l.Items = append(l.Items[:i], l.Items[i+1:]...)
+			return None, nil
+		}
+	}
+	return nil, Errorf(ValueError, "list.remove(x): x not in list")
+}
+
+// list.index(value, start=0, stop=len), the bounds are clamped like a slice
+func listIndex(self Value, args []Value, kwargs map[string]Value) (Value, error) {
+	if err := noKeywords("index", kwargs); err != nil {
+		return nil, err
+	}
+	i, err := indexOf(self.(*List).Items, args)
+	if err != nil {
+		return nil, err
+	}
+	if i < 0 {
+		return nil, Errorf(ValueError, "%s is not in list", args[0].Repr())
+	}
+	return Int(i), nil
+}
+
+// position of args[0] in items between the optional bounds args[1] and
+// args[2], -1 when it is missing
+func indexOf(items []Value, args []Value) (int, error) {
+	if len(args) == 0 {
+		return -1, Errorf(TypeError, "index expected at least 1 argument, got 0")
+	}
+	if err := atMostArgs("index", args, 3); err != nil {
+		return -1, err
+	}
+	bounds := []int{0, len(items)}
+	for i, v := range args[1:] {
+		n, ok := clampInt(v)
+		if !ok {
+			return -1, Errorf(TypeError, "slice indices must be integers or have an __index__ method")
+		}
+		if n < 0 {
+			n += int64(len(items))
+			if n < 0 {
+				n = 0
+			}
+		}
+		if n > int64(len(items)) {
+			n = int64(len(items))
+		}
+		bounds[i] = int(n)
+	}
+	for i := bounds[0]; i < bounds[1] && i < len(items); i++ {
+		if Is(items[i], args[0]) || Equal(items[i], args[0]) {
+			return i, nil
+		}
+	}
+	return -1, nil
+}
+
+// list.count(value)
+func listCount(self Value, args []Value, kwargs map[string]Value) (Value, error) {
+	if err := noKeywords("list.count", kwargs); err != nil {
+		return nil, err
+	}
+	if err := exactArgs("list.count", args, 1); err != nil {
+		return nil, err
+	}
+	return countOf(self.(*List).Items, args[0]), nil
+}
+
+// number of items equal to v
+func countOf(items []Value, v Value) Int {
+	n := 0
+	for _, item := range items {
+		if Is(item, v) || Equal(item, v) {
+			n++
+		}
+	}
+	return Int(n)
+}
+
+// list.reverse(), in place
+func listReverse(self Value, args []Value, kwargs map[string]Value) (Value, error) {
+	if err := noArgs("list.reverse", args, kwargs); err != nil {
+		return nil, err
+	}
+	reverse(self.(*List).Items)
+	return None, nil
+}
+
+// list.copy(), a shallow copy
+func listCopy(self Value, args []Value, kwargs map[string]Value) (Value, error) {
+	if err := noArgs("list.copy", args, kwargs); err != nil {
+		return nil, err
+	}
+	return &List{Items: append([]Value(nil), self.(*List).Items...)}, nil
+}
+
+// list.clear()
+func listClear(self Value, args []Value, kwargs map[string]Value) (Value, error) {
+	if err := noArgs("list.clear", args, kwargs); err != nil {
+		return nil, err
+	}
+	self.(*List).Items = nil
+	return None, nil
+}
+
+// list.sort(*, key=None, reverse=False), stable and in place
+func listSort(self Value, args []Value, kwargs map[string]Value) (Value, error) {
+	if len(args) > 0 {
+		return nil, Errorf(TypeError, "sort() takes no positional arguments")
+	}
+	var key Value = None
+	descending := false
+	for name, v := range kwargs {
+		switch name {
+		case "key":
+			key = v
+		case "reverse":
+			descending = v.Truth()
+		default:
+			return nil, Errorf(TypeError, "'%s' is an invalid keyword argument for sort()", name)
+		}
+	}
+	l := self.(*List)
+	items := append([]Value(nil), l.Items...)
+	keys := items
+	if key != None {
+		f, ok := key.(Callable)
+		if !ok {
+			return nil, Errorf(TypeError, "'%s' object is not callable", key.Type().Name)
+		}
+		keys = make([]Value, len(items))
+		for i, item := range items {
+			k, err := f.Call([]Value{item}, nil)
+			if err != nil {
+				return nil, err
+			}
+			keys[i] = k
+		}
+	}
+
+	// reversing before and after the stable sort keep equal items in
+	// their order, like CPython
+	order := make([]int, len(items))
+	for i := range order {
+		order[i] = i
+	}
+	if descending {
+		reverseInts(order)
+	}
+	var err error
+	sort.SliceStable(order, func(i, j int) bool {
+		if err != nil {
+			return false
+		}
+		var less Value
+		less, err = Compare(LESSTHAN, keys[order[i]], keys[order[j]])
+		return err == nil && less.Truth()
+	})
+	if err != nil {
+		return nil, err
+	}
+	if descending {
+		reverseInts(order)
+	}
+	for i, k := range order {
+		l.Items[i] = items[k]
+	}
+	return None, nil
+}
+
+func reverse(items []Value) {
+	for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+		items[i], items[j] = items[j], items[i]
+	}
+}
+
+func reverseInts(items []int) {
+	for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+		items[i], items[j] = items[j], items[i]
+	}
+}
+
+// TypeError unless the method is called without arguments
+func noArgs(name string, args []Value, kwargs map[string]Value) error {
+	if err := noKeywords(name, kwargs); err != nil {
+		return err
+	}
+	if len(args) > 0 {
+		return Errorf(TypeError, "%s() takes no arguments (%d given)", name, len(args))
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package object
+
+import (
+	"fmt"
+	"io"
+	"math"
+)
+
+// module object, its attributes are the names it defines
+type Module struct {
+	Name  string
+	Attrs map[string]Value
+}
+
+var ModuleType = &Type{Name: "module"}
+
+func (m *Module) Type() *Type  { return ModuleType }
+func (m *Module) Truth() bool  { return true }
+func (m *Module) Repr() string { return fmt.Sprintf("<module %s (built-in)>", Str(m.Name).Repr()) }
+
+// __import__(name), sys is the only module. A module is made once per
+// interpreter
+func (s *Streams) importModule(args []Value, kwargs map[string]Value) (Value, error) {
+	if err := noKeywords("__import__", kwargs); err != nil {
+		return nil, err
+	}
+	if err := exactArgs("__import__", args, 1); err != nil {
+		return nil, err
+	}
+	name, ok := args[0].(Str)
+	if !ok {
+		return nil, Errorf(TypeError, "__import__() argument 1 must be str, not %s", args[0].Type().Name)
+	}
+	if name != "sys" {
+		return nil, Errorf(ModuleNotFoundError, "No module named %s", name.Repr())
+	}
+	if s.sys == nil {
+		s.sys = s.newSys()
+	}
+	return s.sys, nil
+}
+
+// the sys module, its standard streams use the ones of s even when they
+// are changed later
+func (s *Streams) newSys() *Module {
+	stdin := &File{name: "<stdin>", mode: "r", reader: s.reader}
+	stdout := &File{name: "<stdout>", mode: "w", writer: func() io.Writer { return s.Stdout }}
+	stderr := &File{name: "<stderr>", mode: "w", writer: func() io.Writer { return s.Stderr }}
+	return &Module{Name: "sys", Attrs: map[string]Value{
+		"stdin": stdin, "stdout": stdout, "stderr": stderr,
+		"__stdin__": stdin, "__stdout__": stdout, "__stderr__": stderr,
+		"maxsize": Int(math.MaxInt64),
+	}}
+}
@@ -0,0 +1,176 @@
+// Runtime values shared by the evaluator and the vm
+package object
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// every runtime value implement Value
+type Value interface {
+	Type() *Type
+	Repr() string // the text repr() return
+	Truth() bool  // truth value used by if and while
+}
+
+// type of a value, ex: int, str, or an exception class
+type Type struct {
+	Name string
+	Base *Type       // parent class, nil for the root types
+	New  BuiltinFunc // called by t(...), nil for exception classes and types that cannot be created
+
+	Methods map[string]MethodFunc // methods found by attribute lookup on the values of the type
+}
+
+var (
+	TypeType     = &Type{Name: "type"}
+	IntType      = &Type{Name: "int"}
+	FloatType    = &Type{Name: "float"}
+	BoolType     = &Type{Name: "bool", Base: IntType}
+	StrType      = &Type{Name: "str"}
+	NoneType     = &Type{Name: "NoneType"}
+	FunctionType = &Type{Name: "function"}
+)
+
+func (t *Type) Type() *Type  { return TypeType }
+func (t *Type) Repr() string { return "<class '" + t.Name + "'>" }
+func (t *Type) Truth() bool  { return true }
+
+type Int int64
+
+func (i Int) Type() *Type  { return IntType }
+func (i Int) Repr() string { return strconv.FormatInt(int64(i), 10) }
+func (i Int) Truth() bool  { return i != 0 }
+
+type Float float64
+
+func (f Float) Type() *Type { return FloatType }
+func (f Float) Truth() bool { return f != 0 }
+
+// shortest text that read back to the same float, like CPython: positional
+// with a "." for decimal exponents from -4 to 15, else 1e+16 or 1.5e-07
+func (f Float) Repr() string {
+	switch {
+	case math.IsInf(float64(f), 1):
+		return "inf"
+	case math.IsInf(float64(f), -1):
+		return "-inf"
+	case math.IsNaN(float64(f)):
+		return "nan"
+	}
+	s := strconv.FormatFloat(float64(f), 'e', -1, 64)
+	if exp, _ := strconv.Atoi(s[strings.IndexByte(s, 'e')+1:]); exp < -4 || exp >= 16 {
+		return s
+	}
+	s = strconv.FormatFloat(float64(f), 'f', -1, 64)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	return s
+}
+
+type Bool bool
+
+const (
+	True  = Bool(true)
+	False = Bool(false)
+)
+
+func (b Bool) Type() *Type { return BoolType }
+func (b Bool) Truth() bool { return bool(b) }
+func (b Bool) Repr() string {
+	if b {
+		return "True"
+	}
+	return "False"
+}
+
+type NoneValue struct{}
+
+// the only NoneValue
+var None = NoneValue{}
+
+func (NoneValue) Type() *Type  { return NoneType }
+func (NoneValue) Repr() string { return "None" }
+func (NoneValue) Truth() bool  { return false }
+
+type Str string
+
+func (s Str) Type() *Type { return StrType }
+func (s Str) Truth() bool { return len(s) > 0 }
+func (s Str) Len() int    { return utf8.RuneCountInString(string(s)) }
+
+// quote with ' unless the string contain ' and no ", like Python. The
+// characters that are not printable are escaped as \xhh, \uhhhh or \Uhhhhhhhh
+func (s Str) Repr() string {
+	quote := byte('\'')
+	if strings.Contains(string(s), "'") && !strings.Contains(string(s), "\"") {
+		quote = '"'
+	}
+	var b strings.Builder
+	b.WriteByte(quote)
+	for _, r := range string(s) {
+		switch {
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == rune(quote):
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r < 0x100 && !unicode.IsPrint(r):
+			b.WriteString(`\x` + strconv.FormatInt(int64(r)+0x100, 16)[1:])
+		case r < 0x10000 && !unicode.IsPrint(r):
+			b.WriteString(`\u` + strconv.FormatInt(int64(r)+0x10000, 16)[1:])
+		case !unicode.IsPrint(r):
+			b.WriteString(`\U` + strconv.FormatInt(int64(r)+0x100000000, 16)[1:])
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte(quote)
+	return b.String()
+}
+
+// the text str() return, the string itself for Str, the message for
+// exceptions and repr for the others
+func StrOf(v Value) string {
+	switch x := v.(type) {
+	case Str:
+		return string(x)
+	case *Exception:
+		return x.Message()
+	}
+	return v.Repr()
+}
+
+// s[i] and s[i:j:k], indexes count characters
+func (s Str) GetItem(key Value) (Value, error) {
+	runes := []rune(string(s))
+	if sl, ok := key.(*Slice); ok {
+		start, step, count, err := sl.Indices(len(runes))
+		if err != nil {
+			return nil, err
+		}
+		out := make([]rune, count)
+		for i := range out {
+			out[i] = runes[start+i*step]
+		}
+		return Str(out), nil
+	}
+	if !isInt(key) {
+		return nil, Errorf(TypeError, "string indices must be integers, not '%s'", key.Type().Name)
+	}
+	i := seqIndex(key, len(runes))
+	if i < 0 {
+		return nil, Errorf(IndexError, "string index out of range")
+	}
+	return Str(runes[i]), nil
+}
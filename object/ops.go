@@ -0,0 +1,696 @@
+// Operators on runtime values, shared by the evaluator and the vm
+package object
+
+import (
+	"math"
+	"math/big"
+	"strings"
+	. "test1/tokenizer"
+)
+
+// text of the operators for error messages, indexed by token category
+var opSymbols = map[int]string{
+	PLUS: "+", MINUS: "-", TIMES: "*", DIV: "/",
+	EQUAL: "==", NOTEQUAL: "!=", LESSTHAN: "<", LESSEQUAL: "<=",
+	GREATERTHAN: ">", GREATEREQUAL: ">=", BITOR: "|", BITAND: "&", BITXOR: "^",
+	FLOORDIV: "//", MOD: "%", POWER: "** or pow()", INVERT: "~", LSHIFT: "<<", RSHIFT: ">>",
+}
+
+// a is b: the same object. Scalars are the same when they hold the same
+// value, a float is itself even when it is nan
+func Is(a, b Value) bool {
+	if x, ok := a.(Float); ok {
+		y, ok := b.(Float)
+		return ok && math.Float64bits(float64(x)) == math.Float64bits(float64(y))
+	}
+	return a == b
+}
+
+// int value of Int and Bool
+func toInt(v Value) (int64, bool) {
+	switch n := v.(type) {
+	case Int:
+		return int64(n), true
+	case Bool:
+		if n {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// float value of Int, Bool, BigInt and Float, inf for a BigInt too large
+func toFloat(v Value) (float64, bool) {
+	switch x := v.(type) {
+	case Float:
+		return float64(x), true
+	case *BigInt:
+		return bigToFloat(x.v), true
+	}
+	i, ok := toInt(v)
+	return float64(i), ok
+}
+
+func isFloat(v Value) bool {
+	_, ok := v.(Float)
+	return ok
+}
+
+// int, float or bool
+func isNumber(v Value) bool {
+	_, ok := toFloat(v)
+	return ok
+}
+
+// ordering of two numbers: -1, 0 or 1, unordered when one is nan. Ints and
+// floats compare exactly, not by the float of the int. ok is false when a
+// or b is not a number
+func cmpNumbers(a, b Value) (c int, unordered, ok bool) {
+	x, ok := toFloat(a)
+	if !ok {
+		return 0, false, false
+	}
+	y, ok := toFloat(b)
+	if !ok {
+		return 0, false, false
+	}
+	if !isFloat(a) && !isFloat(b) {
+		if xi, ok := toInt(a); ok {
+			if yi, ok := toInt(b); ok {
+				return cmpInt(xi, yi), false, true
+			}
+		}
+		xb, _ := toBig(a)
+		yb, _ := toBig(b)
+		return xb.Cmp(yb), false, true
+	}
+	if x != x || y != y {
+		return 0, true, true
+	}
+	if !isFloat(a) {
+		return cmpIntFloat(a, y), false, true
+	}
+	if !isFloat(b) {
+		return -cmpIntFloat(b, x), false, true
+	}
+	return cmpFloat(x, y), false, true
+}
+
+// ordering of an int and a float that is not nan. An int beyond 2**53 may
+// not have an exact float so it is compared as a big float
+func cmpIntFloat(i Value, f float64) int {
+	const exact = 1 << 53
+	if math.IsInf(f, 0) {
+		return -int(math.Copysign(1, f))
+	}
+	if n, ok := toInt(i); ok && -exact <= n && n <= exact {
+		return cmpFloat(float64(n), f)
+	}
+	b, _ := toBig(i)
+	return new(big.Float).SetInt(b).Cmp(big.NewFloat(f))
+}
+
+func cmpFloat(x, y float64) int {
+	if x < y {
+		return -1
+	} else if x > y {
+		return 1
+	}
+	return 0
+}
+
+// == on two values, numbers compare by value across int, float and bool
+func Equal(a, b Value) bool {
+	if isNumber(a) {
+		c, unordered, ok := cmpNumbers(a, b)
+		return ok && !unordered && c == 0
+	}
+	switch x := a.(type) {
+	case Str:
+		y, ok := b.(Str)
+		return ok && x == y
+	case *List:
+		y, ok := b.(*List)
+		return ok && (x == y || equalItems(x.Items, y.Items))
+	case *Tuple:
+		y, ok := b.(*Tuple)
+		return ok && (x == y || equalItems(x.Items, y.Items))
+	case *Dict:
+		y, ok := b.(*Dict)
+		return ok && (x == y || x.equal(y))
+	case *DictView:
+		y, ok := b.(*DictView)
+		return ok && x.equal(y)
+	case *Set:
+		y, ok := b.(*Set)
+		return ok && (x == y || x.equal(y))
+	case *Range:
+		y, ok := b.(*Range)
+		return ok && x.equal(y)
+	}
+	return a == b
+}
+
+func equalItems(x, y []Value) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if !Is(x[i], y[i]) && !Equal(x[i], y[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// items of two lists or of two tuples, compared in order
+func sameSequences(a, b Value) ([]Value, []Value, bool) {
+	switch x := a.(type) {
+	case *List:
+		if y, ok := b.(*List); ok {
+			return x.Items, y.Items, true
+		}
+	case *Tuple:
+		if y, ok := b.(*Tuple); ok {
+			return x.Items, y.Items, true
+		}
+	}
+	return nil, nil, false
+}
+
+// comparison operators, op is the token category
+func Compare(op int, a, b Value) (Value, error) {
+	switch op {
+	case EQUAL:
+		return Bool(Equal(a, b)), nil
+	case NOTEQUAL:
+		return Bool(!Equal(a, b)), nil
+	case IN:
+		in, err := Contains(b, a)
+		return Bool(in), err
+	case NOTIN:
+		in, err := Contains(b, a)
+		return Bool(!in), err
+	case IS:
+		return Bool(Is(a, b)), nil
+	case ISNOT:
+		return Bool(!Is(a, b)), nil
+	}
+
+	// ordering: -1, 0 or 1
+	var c int
+	if isNumber(a) {
+		var unordered, ok bool
+		if c, unordered, ok = cmpNumbers(a, b); !ok {
+			return nil, compareError(op, a, b)
+		}
+		if unordered { // nan is not ordered
+			return False, nil
+		}
+	} else if x, ok := a.(Str); ok {
+		y, ok := b.(Str)
+		if !ok {
+			return nil, compareError(op, a, b)
+		}
+		c = strings.Compare(string(x), string(y))
+	} else if x, y, ok := sameSequences(a, b); ok {
+		// the first items that differ decide, else the shorter one is smaller
+		for i := 0; i < len(x) && i < len(y); i++ {
+			if !Is(x[i], y[i]) && !Equal(x[i], y[i]) {
+				return Compare(op, x[i], y[i])
+			}
+		}
+		c = cmpInt(int64(len(x)), int64(len(y)))
+	} else if x, ok := a.(*Set); ok {
+		y, ok := b.(*Set)
+		if !ok {
+			return nil, compareError(op, a, b)
+		}
+		return x.compare(op, y), nil
+	} else {
+		return nil, compareError(op, a, b)
+	}
+
+	switch op {
+	case LESSTHAN:
+		return Bool(c < 0), nil
+	case LESSEQUAL:
+		return Bool(c <= 0), nil
+	case GREATERTHAN:
+		return Bool(c > 0), nil
+	case GREATEREQUAL:
+		return Bool(c >= 0), nil
+	}
+	return nil, Errorf(SystemError, "unknown comparison %s", Describe(op))
+}
+
+func cmpInt(x, y int64) int {
+	if x < y {
+		return -1
+	} else if x > y {
+		return 1
+	}
+	return 0
+}
+
+func compareError(op int, a, b Value) *Exception {
+	return Errorf(TypeError, "'%s' not supported between instances of '%s' and '%s'",
+		opSymbols[op], a.Type().Name, b.Type().Name)
+}
+
+// arithmetic, shift, bitwise and set operators, op is the token category
+func BinaryOp(op int, a, b Value) (Value, error) {
+	// bitwise operators on two bools give a bool
+	if x, ok := a.(Bool); ok {
+		if y, ok := b.(Bool); ok {
+			switch op {
+			case BITAND:
+				return x && y, nil
+			case BITOR:
+				return x || y, nil
+			case BITXOR:
+				return Bool(x != y), nil
+			}
+		}
+	}
+
+	// int and bool arithmetic stay int, except true division. Small ints
+	// take the int64 fast path, big ones go through math/big
+	if x, ok := toInt(a); ok {
+		if y, ok := toInt(b); ok {
+			if v, err := intOp(op, x, y); v != nil || err != nil {
+				return v, err
+			}
+		}
+	}
+	if x, ok := toBig(a); ok {
+		if y, ok := toBig(b); ok {
+			if v, err := bigOp(op, x, y); v != nil || err != nil {
+				return v, err
+			}
+		}
+	}
+
+	// any float make the result a float, an int must fit in a float
+	if x, ok := toFloat(a); ok {
+		if y, ok := toFloat(b); ok {
+			if v, err := floatOp(op, x, y); v != nil || err != nil {
+				if err := floatOverflow(a, b); err != nil {
+					return nil, err
+				}
+				return v, err
+			}
+		}
+	}
+
+	switch x := a.(type) {
+	case Str:
+		if y, ok := b.(Str); ok && op == PLUS {
+			return x + y, nil // string concat
+		}
+		if op == PLUS {
+			return nil, Errorf(TypeError, "can only concatenate str (not \"%s\") to str", b.Type().Name)
+		}
+	case *List:
+		if y, ok := b.(*List); ok && op == PLUS {
+			items := make([]Value, 0, len(x.Items)+len(y.Items))
+			return &List{Items: append(append(items, x.Items...), y.Items...)}, nil
+		}
+		if op == PLUS {
+			return nil, Errorf(TypeError, "can only concatenate list (not \"%s\") to list", b.Type().Name)
+		}
+	case *Tuple:
+		if y, ok := b.(*Tuple); ok && op == PLUS {
+			items := make([]Value, 0, len(x.Items)+len(y.Items))
+			return &Tuple{Items: append(append(items, x.Items...), y.Items...)}, nil
+		}
+		if op == PLUS {
+			return nil, Errorf(TypeError, "can only concatenate tuple (not \"%s\") to tuple", b.Type().Name)
+		}
+	case *Set:
+		if y, ok := b.(*Set); ok {
+			if v, ok := x.binaryOp(op, y); ok {
+				return v, nil
+			}
+		}
+	}
+
+	// sequence repetition, the count can be on either side
+	if op == TIMES {
+		seq, count := a, b
+		if isInt(a) {
+			seq, count = b, a
+		}
+		switch seq.(type) {
+		case Str, *List, *Tuple:
+			if _, ok := count.(*BigInt); ok {
+				return nil, Errorf(OverflowError, "cannot fit 'int' into an index-sized integer")
+			}
+			n, ok := toInt(count)
+			if !ok {
+				return nil, Errorf(TypeError, "can't multiply sequence by non-int of type '%s'", count.Type().Name)
+			}
+			switch s := seq.(type) {
+			case Str:
+				return repeat(s, n)
+			case *List:
+				l, err := s.repeat(n)
+				if err != nil {
+					return nil, err
+				}
+				return l, nil
+			case *Tuple:
+				t, err := s.repeat(n)
+				if err != nil {
+					return nil, err
+				}
+				return t, nil
+			}
+		}
+	}
+	return nil, Errorf(TypeError, "unsupported operand type(s) for %s: '%s' and '%s'",
+		opSymbols[op], a.Type().Name, b.Type().Name)
+}
+
+// op on two ints, nil when it is not an int operator. A result that
+// overflow an int64 is computed again by bigOp
+func intOp(op int, x, y int64) (Value, error) {
+	switch op {
+	case PLUS:
+		if s := x + y; (s > x) == (y > 0) {
+			return Int(s), nil
+		}
+	case MINUS:
+		if d := x - y; (d < x) == (y > 0) {
+			return Int(d), nil
+		}
+	case TIMES:
+		if p, ok := mulInt(x, y); ok {
+			return Int(p), nil
+		}
+	case DIV:
+		if y == 0 {
+			return nil, Errorf(ZeroDivisionError, "division by zero")
+		}
+		// beyond 2**53 the floats of the ints are not exact
+		const exact = 1 << 53
+		if -exact <= x && x <= exact && -exact <= y && y <= exact {
+			return Float(float64(x) / float64(y)), nil
+		}
+	case FLOORDIV:
+		if y == 0 {
+			return nil, Errorf(ZeroDivisionError, "integer division or modulo by zero")
+		}
+		if x == math.MinInt64 && y == -1 {
+			break
+		}
+		// round toward negative infinity, Go truncate toward zero
+		q := x / y
+		if x%y != 0 && (x < 0) != (y < 0) {
+			q--
+		}
+		return Int(q), nil
+	case MOD:
+		if y == 0 {
+			return nil, Errorf(ZeroDivisionError, "integer modulo by zero")
+		}
+		// the result has the sign of the divisor
+		r := x % y
+		if r != 0 && (r < 0) != (y < 0) {
+			r += y
+		}
+		return Int(r), nil
+	case POWER:
+		if y < 0 {
+			return floatOp(op, float64(x), float64(y))
+		}
+		if result, ok := powInt(x, y); ok {
+			return Int(result), nil
+		}
+	case LSHIFT, RSHIFT:
+		if y < 0 {
+			return nil, Errorf(ValueError, "negative shift count")
+		}
+		if op == LSHIFT {
+			if x == 0 {
+				return Int(0), nil
+			}
+			if y < 64 && x<<uint(y)>>uint(y) == x {
+				return Int(x << uint(y)), nil
+			}
+			break
+		}
+		if y >= 64 {
+			y = 63 // only the sign is left
+		}
+		return Int(x >> uint(y)), nil
+	case BITAND:
+		return Int(x & y), nil
+	case BITOR:
+		return Int(x | y), nil
+	case BITXOR:
+		return Int(x ^ y), nil
+	default:
+		return nil, nil
+	}
+	return bigOp(op, big.NewInt(x), big.NewInt(y))
+}
+
+// x * y, false when it overflow
+func mulInt(x, y int64) (int64, bool) {
+	p := x * y
+	if x != 0 && (p/x != y || x == -1 && y == math.MinInt64) {
+		return 0, false
+	}
+	return p, true
+}
+
+// x ** y for y >= 0 by repeated squaring, false when it overflow
+func powInt(x, y int64) (int64, bool) {
+	result := int64(1)
+	for ok := true; ; {
+		if y&1 == 1 {
+			if result, ok = mulInt(result, x); !ok {
+				return 0, false
+			}
+		}
+		if y >>= 1; y == 0 {
+			return result, true
+		}
+		if x, ok = mulInt(x, x); !ok {
+			return 0, false
+		}
+	}
+}
+
+// op on two floats, nil when it is not a float operator
+func floatOp(op int, x, y float64) (Value, error) {
+	switch op {
+	case PLUS:
+		return Float(x + y), nil
+	case MINUS:
+		return Float(x - y), nil
+	case TIMES:
+		return Float(x * y), nil
+	case DIV:
+		if y == 0 {
+			return nil, Errorf(ZeroDivisionError, "float division by zero")
+		}
+		return Float(x / y), nil
+	case FLOORDIV:
+		if y == 0 {
+			return nil, Errorf(ZeroDivisionError, "float floor division by zero")
+		}
+		return Float(floatFloorDiv(x, y)), nil
+	case MOD:
+		if y == 0 {
+			return nil, Errorf(ZeroDivisionError, "float modulo")
+		}
+		return Float(floatMod(x, y)), nil
+	case POWER:
+		return floatPow(x, y)
+	}
+	return nil, nil
+}
+
+// x % y with the sign of y, like CPython
+func floatMod(x, y float64) float64 {
+	mod := math.Mod(x, y)
+	if mod == 0 {
+		return math.Copysign(0, y)
+	}
+	if (y < 0) != (mod < 0) {
+		mod += y
+	}
+	return mod
+}
+
+// x // y computed from the remainder like CPython, so x == (x // y) * y + x % y
+// as close as floats allow
+func floatFloorDiv(x, y float64) float64 {
+	mod := math.Mod(x, y)
+	div := (x - mod) / y
+	if mod != 0 && (y < 0) != (mod < 0) {
+		div -= 1
+	}
+	if div == 0 {
+		return math.Copysign(0, x/y)
+	}
+	floor := math.Floor(div)
+	if div-floor > 0.5 {
+		floor++
+	}
+	return floor
+}
+
+// x ** y, a result that would be complex is a ValueError since there are
+// no complex numbers
+func floatPow(x, y float64) (Value, error) {
+	if x == 0 && y < 0 {
+		return nil, Errorf(ZeroDivisionError, "0.0 cannot be raised to a negative power")
+	}
+	if x < 0 && !math.IsInf(x, 0) && y != math.Floor(y) && !math.IsInf(y, 0) && !math.IsNaN(y) {
+		return nil, Errorf(ValueError, "negative number cannot be raised to a fractional power")
+	}
+	r := math.Pow(x, y)
+	if math.IsInf(r, 0) && !math.IsInf(x, 0) && !math.IsInf(y, 0) {
+		return nil, Errorf(OverflowError, "(34, 'Numerical result out of range')")
+	}
+	return Float(r), nil
+}
+
+// string multiplication, "a" * 3 = "aaa", negative count give empty string
+func repeat(s Str, n int64) (Value, error) {
+	if n <= 0 || len(s) == 0 {
+		return Str(""), nil
+	}
+	if n > math.MaxInt/int64(len(s)) {
+		return nil, Errorf(OverflowError, "repeated string is too long")
+	}
+	if n > maxAlloc/int64(len(s)) {
+		return nil, &Exception{Class: MemoryError}
+	}
+	return Str(strings.Repeat(string(s), int(n))), nil
+}
+
+// unary "+", "-", "~" and "not", op is the token category
+func UnaryOp(op int, v Value) (Value, error) {
+	if op == NOT {
+		return Bool(!v.Truth()), nil
+	}
+	switch n := v.(type) {
+	case Float:
+		switch op {
+		case MINUS:
+			return -n, nil
+		case PLUS:
+			return n, nil
+		}
+	case Int, Bool:
+		i, _ := toInt(n)
+		switch op {
+		case MINUS:
+			if i == math.MinInt64 {
+				return NewInt(new(big.Int).Neg(big.NewInt(i))), nil
+			}
+			return Int(-i), nil
+		case INVERT:
+			return Int(^i), nil
+		}
+		return Int(i), nil
+	case *BigInt:
+		switch op {
+		case MINUS:
+			return NewInt(new(big.Int).Neg(n.v)), nil
+		case INVERT:
+			return NewInt(new(big.Int).Not(n.v)), nil
+		}
+		return n, nil
+	}
+	return nil, Errorf(TypeError, "bad operand type for unary %s: '%s'", opSymbols[op], v.Type().Name)
+}
+
+// container with items found by x[key]
+type Indexable interface {
+	Value
+	GetItem(key Value) (Value, error)
+}
+
+// container whose items can be changed with x[key] = v and del x[key]
+type ItemSetter interface {
+	Value
+	SetItem(key, v Value) error
+	DelItem(key Value) error
+}
+
+// x[key]
+func GetItem(x, key Value) (Value, error) {
+	if c, ok := x.(Indexable); ok {
+		return c.GetItem(key)
+	}
+	return nil, Errorf(TypeError, "'%s' object is not subscriptable", x.Type().Name)
+}
+
+// x[key] = v
+func SetItem(x, key, v Value) error {
+	if c, ok := x.(ItemSetter); ok {
+		return c.SetItem(key, v)
+	}
+	return Errorf(TypeError, "'%s' object does not support item assignment", x.Type().Name)
+}
+
+// del x[key]
+func DelItem(x, key Value) error {
+	if c, ok := x.(ItemSetter); ok {
+		return c.DelItem(key)
+	}
+	return Errorf(TypeError, "'%s' object doesn't support item deletion", x.Type().Name)
+}
+
+// item in container
+func Contains(container, item Value) (bool, error) {
+	switch c := container.(type) {
+	case Str:
+		s, ok := item.(Str)
+		if !ok {
+			return false, Errorf(TypeError, "'in <string>' requires string as left operand, not %s", item.Type().Name)
+		}
+		return strings.Contains(string(c), string(s)), nil
+	case *List:
+		return containsItem(c.Items, item), nil
+	case *Tuple:
+		return containsItem(c.Items, item), nil
+	case *Dict:
+		_, ok, err := c.Get(item)
+		return ok, err
+	case *DictView:
+		return c.contains(item)
+	case *Set:
+		return c.Contains(item)
+	case *Range:
+		return c.contains(item), nil
+	case Iterator:
+		// consume the items up to the one found
+		for {
+			v, ok, err := c.Next()
+			if err != nil || !ok {
+				return false, err
+			}
+			if Is(v, item) || Equal(v, item) {
+				return true, nil
+			}
+		}
+	}
+	return false, Errorf(TypeError, "argument of type '%s' is not iterable", container.Type().Name)
+}
+
+// an item is the value itself or equal to it
+func containsItem(items []Value, v Value) bool {
+	for _, item := range items {
+		if Is(item, v) || Equal(item, v) {
+			return true
+		}
+	}
+	return false
+}
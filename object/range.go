@@ -0,0 +1,285 @@
+package object
+
+import "math/big"
+
+// immutable arithmetic sequence made by range(), its items are computed
+// when they are asked for so a long range take no memory. The bounds are
+// ints of any size, like in CPython only len() need the length to be small
+type Range struct {
+	start, stop, step *big.Int // never changed once the range is made
+	length            *big.Int
+	small             bool // the bounds and the length fit in an int64
+}
+
+var RangeType = &Type{Name: "range"}
+
+func (r *Range) Type() *Type { return RangeType }
+func (r *Range) Truth() bool { return r.length.Sign() > 0 }
+
+// len(r), OverflowError when the length does not fit in an int
+func (r *Range) lenValue() (Value, error) {
+	if !r.length.IsInt64() {
+		return nil, Errorf(OverflowError, "Python int too large to convert to C ssize_t")
+	}
+	return Int(r.length.Int64()), nil
+}
+
+// range(0, 5), the step is shown when it is not 1
+func (r *Range) Repr() string {
+	s := "range(" + r.start.String() + ", " + r.stop.String()
+	if !r.step.IsInt64() || r.step.Int64() != 1 {
+		s += ", " + r.step.String()
+	}
+	return s + ")"
+}
+
+func newRangeOf(start, stop, step *big.Int) *Range {
+	r := &Range{start: start, stop: stop, step: step, length: new(big.Int)}
+	if step.Sign() > 0 && start.Cmp(stop) < 0 {
+		r.length.Sub(stop, start)
+	} else if step.Sign() < 0 && stop.Cmp(start) < 0 {
+		r.length.Sub(start, stop)
+	}
+	if r.length.Sign() > 0 {
+		// (distance - 1) / |step| + 1
+		r.length.Sub(r.length, big.NewInt(1))
+		r.length.Quo(r.length, new(big.Int).Abs(step))
+		r.length.Add(r.length, big.NewInt(1))
+	}
+	r.small = start.IsInt64() && stop.IsInt64() && step.IsInt64() && r.length.IsInt64()
+	return r
+}
+
+// item i of the range, i may be out of range for the bounds of a slice
+func (r *Range) item(i *big.Int) *big.Int {
+	z := new(big.Int).Mul(i, r.step)
+	return z.Add(z, r.start)
+}
+
+// r[i] and r[i:j:k], a slice of a range is a range
+func (r *Range) GetItem(key Value) (Value, error) {
+	if s, ok := key.(*Slice); ok {
+		start, stop, step, err := s.bigBounds(r.length)
+		if err != nil {
+			return nil, err
+		}
+		return newRangeOf(r.item(start), r.item(stop), new(big.Int).Mul(r.step, step)), nil
+	}
+	if r.small && isInt(key) {
+		// the items are between start and stop so they fit in an int64
+		i := seqIndex(key, int(r.length.Int64()))
+		if i < 0 {
+			return nil, Errorf(IndexError, "range object index out of range")
+		}
+		return Int(r.start.Int64() + int64(i)*r.step.Int64()), nil
+	}
+	k, ok := toBig(key)
+	if !ok {
+		return nil, Errorf(TypeError, "range indices must be integers or slices, not %s", key.Type().Name)
+	}
+	i := new(big.Int).Set(k)
+	if i.Sign() < 0 {
+		i.Add(i, r.length)
+	}
+	if i.Sign() < 0 || i.Cmp(r.length) >= 0 {
+		return nil, Errorf(IndexError, "range object index out of range")
+	}
+	return NewInt(r.item(i)), nil
+}
+
+// start, stop and step of a slice of a sequence of length n, like bounds but
+// with ints of any size
+func (s *Slice) bigBounds(n *big.Int) (start, stop, step *big.Int, err error) {
+	index := func(v Value) (*big.Int, error) {
+		i, ok := toBig(v)
+		if !ok {
+			return nil, Errorf(TypeError, "slice indices must be integers or None or have an __index__ method")
+		}
+		return i, nil
+	}
+	step = big.NewInt(1)
+	if s.Step != None {
+		if step, err = index(s.Step); err != nil {
+			return
+		}
+		if step.Sign() == 0 {
+			return nil, nil, nil, Errorf(ValueError, "slice step cannot be zero")
+		}
+	}
+	// defaults and clamping as in CPython, -1 is before the first item when going backward
+	lower, upper := big.NewInt(0), n
+	if step.Sign() < 0 {
+		lower, upper = big.NewInt(-1), new(big.Int).Sub(n, big.NewInt(1))
+	}
+	bound := func(v Value, deflt *big.Int) (*big.Int, error) {
+		if v == None {
+			return deflt, nil
+		}
+		i, err := index(v)
+		if err != nil {
+			return nil, err
+		}
+		if i.Sign() < 0 {
+			i = new(big.Int).Add(i, n)
+			if i.Sign() < 0 {
+				i = lower
+			}
+		} else if i.Cmp(upper) > 0 {
+			i = upper
+		}
+		return i, nil
+	}
+	startDefault, stopDefault := lower, upper
+	if step.Sign() < 0 {
+		startDefault, stopDefault = upper, lower
+	}
+	if start, err = bound(s.Start, startDefault); err != nil {
+		return
+	}
+	if stop, err = bound(s.Stop, stopDefault); err != nil {
+		return
+	}
+	return start, stop, step, nil
+}
+
+// position of v in r, nil when it is not there. Ints are found by
+// arithmetic, other values by comparing them to each item
+func (r *Range) find(v Value) *big.Int {
+	if _, ok := v.(Float); !ok {
+		if i, ok := toBig(v); ok {
+			if r.length.Sign() == 0 {
+				return nil
+			}
+			n, m := new(big.Int).QuoRem(new(big.Int).Sub(i, r.start), r.step, new(big.Int))
+			if m.Sign() != 0 || n.Sign() < 0 || n.Cmp(r.length) >= 0 {
+				return nil
+			}
+			return n
+		}
+	}
+	it := r.iter()
+	for n := int64(0); ; n++ {
+		item, ok, _ := it.Next()
+		if !ok {
+			return nil
+		}
+		if Equal(item, v) {
+			return big.NewInt(n)
+		}
+	}
+}
+
+func (r *Range) contains(v Value) bool {
+	return r.find(v) != nil
+}
+
+// ranges are equal when they hold the same items, whatever their bounds
+func (r *Range) equal(other *Range) bool {
+	if r == other {
+		return true
+	}
+	if r.length.Cmp(other.length) != 0 {
+		return false
+	}
+	return r.length.Sign() == 0 || r.start.Cmp(other.start) == 0 &&
+		(r.length.Cmp(big.NewInt(1)) == 0 || r.step.Cmp(other.step) == 0)
+}
+
+// hash of the items like CPython: of (len, start, step) with None for the
+// parts that do not change the items
+func (r *Range) hash() (int64, error) {
+	t := &Tuple{Items: []Value{NewInt(r.length), None, None}}
+	if r.length.Sign() > 0 {
+		t.Items[1] = NewInt(r.start)
+		if r.length.Cmp(big.NewInt(1)) > 0 {
+			t.Items[2] = NewInt(r.step)
+		}
+	}
+	return t.hash()
+}
+
+func (r *Range) iter() Iterator {
+	if r.small {
+		start, step, length := r.start.Int64(), r.step.Int64(), r.length.Int64()
+		i := int64(0)
+		return &iterator{typ: RangeIteratorType, next: func() (Value, bool, error) {
+			if i >= length {
+				return nil, false, nil
+			}
+			i++
+			return Int(start + (i-1)*step), true, nil
+		}}
+	}
+	next, left := r.start, new(big.Int).Set(r.length)
+	return &iterator{typ: RangeIteratorType, next: func() (Value, bool, error) {
+		if left.Sign() == 0 {
+			return nil, false, nil
+		}
+		left.Sub(left, big.NewInt(1))
+		v := next
+		next = new(big.Int).Add(next, r.step)
+		return NewInt(v), true, nil
+	}}
+}
+
+func init() {
+	RangeType.New = newRange
+	RangeType.Methods = map[string]MethodFunc{
+		"index": rangeIndex,
+		"count": rangeCount,
+	}
+}
+
+// range(stop) or range(start, stop[, step])
+func newRange(args []Value, kwargs map[string]Value) (Value, error) {
+	if err := noKeywords("range", kwargs); err != nil {
+		return nil, err
+	}
+	if err := rangeArgs("range", args, 1, 3); err != nil {
+		return nil, err
+	}
+	bounds := []*big.Int{big.NewInt(0), big.NewInt(0), big.NewInt(1)}
+	for i, a := range args {
+		n, ok := toBig(a)
+		if !ok {
+			return nil, Errorf(TypeError, "'%s' object cannot be interpreted as an integer", a.Type().Name)
+		}
+		bounds[i] = n
+	}
+	if len(args) == 1 {
+		bounds[0], bounds[1] = big.NewInt(0), bounds[0]
+	}
+	if bounds[2].Sign() == 0 {
+		return nil, Errorf(ValueError, "range() arg 3 must not be zero")
+	}
+	return newRangeOf(bounds[0], bounds[1], bounds[2]), nil
+}
+
+// range.index(value)
+func rangeIndex(self Value, args []Value, kwargs map[string]Value) (Value, error) {
+	if err := noKeywords("index", kwargs); err != nil {
+		return nil, err
+	}
+	if err := exactArgs("index", args, 1); err != nil {
+		return nil, err
+	}
+	i := self.(*Range).find(args[0])
+	if i == nil {
+		return nil, Errorf(ValueError, "%s is not in range", args[0].Repr())
+	}
+	return NewInt(i), nil
+}
+
+// range.count(value), the items are distinct so it is 0 or 1
+func rangeCount(self Value, args []Value, kwargs map[string]Value) (Value, error) {
+	if err := noKeywords("count", kwargs); err != nil {
+		return nil, err
+	}
+	if err := exactArgs("count", args, 1); err != nil {
+		return nil, err
+	}
+	if self.(*Range).contains(args[0]) {
+		return Int(1), nil
+	}
+	return Int(0), nil
+}
@@ -0,0 +1,541 @@
+package object
+
+import (
+	"strings"
+	. "test1/tokenizer"
+)
+
+// unordered collection of distinct hashable values. The items live in an
+// open addressing table probed like the one of CPython, so a set iterate
+// and print its items in the same order as CPython does
+type Set struct {
+	table  []setEntry // nil until the first item is added, then a power of 2 long
+	fill   int        // active and dummy entries
+	used   int        // active entries
+	finger int        // where pop start looking for an item
+}
+
+// a free slot has a nil key, a removed item leave a dummy that keep the
+// probe chains going until the next resize
+type setEntry struct {
+	key   Value
+	hash  int64
+	dummy bool
+}
+
+const (
+	setMinSize   = 8
+	linearProbes = 9
+	perturbShift = 5
+)
+
+var SetType = &Type{Name: "set"}
+
+func (s *Set) Type() *Type { return SetType }
+func (s *Set) Truth() bool { return s.used > 0 }
+func (s *Set) Len() int    { return s.used }
+
+// {1, 2}, the empty set is set() since {} is a dict
+func (s *Set) Repr() string {
+	if s.used == 0 {
+		return "set()"
+	}
+	items := s.Items()
+	reprs := make([]string, len(items))
+	for i, v := range items {
+		reprs[i] = v.Repr()
+	}
+	return "{" + strings.Join(reprs, ", ") + "}"
+}
+
+// the items in table order
+func (s *Set) Items() []Value {
+	items := make([]Value, 0, s.used)
+	for _, e := range s.table {
+		if e.key != nil {
+			items = append(items, e.key)
+		}
+	}
+	return items
+}
+
+func (s *Set) mask() int {
+	if s.table == nil {
+		return setMinSize - 1
+	}
+	return len(s.table) - 1
+}
+
+// slot of key, or of the free slot ending its probe chain when it is missing
+func (s *Set) probe(key Value, h int64) (int, bool) {
+	mask := uint64(len(s.table) - 1)
+	perturb := uint64(h)
+	i := uint64(h) & mask
+	for {
+		// a few slots next to each other are tried before jumping away
+		probes := uint64(0)
+		if i+linearProbes <= mask {
+			probes = linearProbes
+		}
+		for j := i; j <= i+probes; j++ {
+			e := &s.table[j]
+			if e.key == nil && !e.dummy {
+				return int(j), false
+			}
+			if e.key != nil && e.hash == h && (Is(e.key, key) || Equal(e.key, key)) {
+				return int(j), true
+			}
+		}
+		perturb >>= perturbShift
+		i = (i*5 + 1 + perturb) & mask
+	}
+}
+
+// add a value to the set
+func (s *Set) Add(key Value) error {
+	h, err := Hash(key)
+	if err != nil {
+		return err
+	}
+	s.insert(key, h)
+	return nil
+}
+
+func (s *Set) insert(key Value, h int64) {
+	if s.table == nil {
+		s.table = make([]setEntry, setMinSize)
+	}
+	i, found := s.probe(key, h)
+	if found {
+		return
+	}
+	s.table[i] = setEntry{key: key, hash: h}
+	s.fill++
+	s.used++
+	if s.fill*5 >= s.mask()*3 {
+		if s.used > 50000 {
+			s.resize(s.used * 2)
+		} else {
+			s.resize(s.used * 4)
+		}
+	}
+}
+
+// move the items to a table bigger than minused, dropping the dummies
+func (s *Set) resize(minused int) {
+	size := setMinSize
+	for size <= minused {
+		size <<= 1
+	}
+	old := s.table
+	s.table = make([]setEntry, size)
+	s.fill = s.used
+	for _, e := range old {
+		if e.key != nil {
+			i, _ := s.probe(e.key, e.hash)
+			s.table[i] = e
+		}
+	}
+}
+
+// true if key is in the set
+func (s *Set) Contains(key Value) (bool, error) {
+	h, err := Hash(key)
+	if err != nil || s.used == 0 {
+		return false, err
+	}
+	_, found := s.probe(key, h)
+	return found, nil
+}
+
+// remove key, false when it is missing
+func (s *Set) Discard(key Value) (bool, error) {
+	h, err := Hash(key)
+	if err != nil || s.used == 0 {
+		return false, err
+	}
+	i, found := s.probe(key, h)
+	if !found {
+		return false, nil
+	}
+	s.table[i] = setEntry{dummy: true}
+	s.used--
+	return true, nil
+}
+
+// add the items of another set, an empty set with the same table size
+// get a copy of its table
+func (s *Set) merge(other *Set) {
+	if other == s || other.used == 0 {
+		return
+	}
+	if s.table == nil {
+		s.table = make([]setEntry, setMinSize)
+	}
+	if (s.fill+other.used)*5 >= s.mask()*3 {
+		s.resize((s.used + other.used) * 2)
+	}
+	if s.fill == 0 && len(s.table) == len(other.table) && other.fill == other.used {
+		copy(s.table, other.table)
+		s.fill, s.used = other.fill, other.used
+		return
+	}
+	for _, e := range other.table {
+		if e.key != nil {
+			s.insert(e.key, e.hash)
+		}
+	}
+}
+
+// add the items of a set, of the keys of a dict or of any iterable
+func (s *Set) Update(v Value) error {
+	switch x := v.(type) {
+	case *Set:
+		s.merge(x)
+		return nil
+	case *Dict:
+		if (s.fill+x.count)*5 >= s.mask()*3 {
+			s.resize((s.used + x.count) * 2)
+		}
+		for _, e := range x.entries {
+			if e.Key != nil {
+				s.insert(e.Key, e.hash)
+			}
+		}
+		return nil
+	}
+	items, err := Iterate(v)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		if err := s.Add(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// add the items of the value of a starred item, ex: {*v}
+func (s *Set) UpdateStarred(v Value) error {
+	switch v.(type) {
+	case *Set, *Dict:
+		return s.Update(v)
+	}
+	items, err := StarredItems(v)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		if err := s.Add(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Set) copy() *Set {
+	c := &Set{}
+	c.merge(s)
+	return c
+}
+
+// the set itself or a new set holding the items of an iterable
+func toSet(v Value) (*Set, error) {
+	if s, ok := v.(*Set); ok {
+		return s, nil
+	}
+	s := &Set{}
+	if err := s.Update(v); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Set) has(e setEntry) bool {
+	if s.used == 0 {
+		return false
+	}
+	_, found := s.probe(e.key, e.hash)
+	return found
+}
+
+func (s *Set) equal(other *Set) bool {
+	return s.used == other.used && s.isSubset(other)
+}
+
+func (s *Set) isSubset(other *Set) bool {
+	if s.used > other.used {
+		return false
+	}
+	for _, e := range s.table {
+		if e.key != nil && !other.has(e) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Set) union(other *Set) *Set {
+	result := s.copy()
+	result.merge(other)
+	return result
+}
+
+// the items of the smaller set that the other one has
+func (s *Set) intersection(other *Set) *Set {
+	if other.used > s.used {
+		s, other = other, s
+	}
+	result := &Set{}
+	for _, e := range other.table {
+		if e.key != nil && s.has(e) {
+			result.insert(e.key, e.hash)
+		}
+	}
+	return result
+}
+
+func (s *Set) difference(other *Set) *Set {
+	// a set much bigger than other is copied then the common items removed
+	if s.used>>2 > other.used {
+		result := s.copy()
+		for _, e := range other.table {
+			if e.key != nil {
+				result.Discard(e.key)
+			}
+		}
+		return result
+	}
+	result := &Set{}
+	for _, e := range s.table {
+		if e.key != nil && !other.has(e) {
+			result.insert(e.key, e.hash)
+		}
+	}
+	return result
+}
+
+// a copy of other, then the items of s are removed from it or added to it
+func (s *Set) symmetricDifference(other *Set) *Set {
+	result := other.copy()
+	for _, e := range s.table {
+		if e.key == nil {
+			continue
+		}
+		if found, _ := result.Discard(e.key); !found {
+			result.insert(e.key, e.hash)
+		}
+	}
+	return result
+}
+
+// the set operators |, &, - and ^
+func (s *Set) binaryOp(op int, other *Set) (Value, bool) {
+	switch op {
+	case BITOR:
+		return s.union(other), true
+	case BITAND:
+		return s.intersection(other), true
+	case MINUS:
+		return s.difference(other), true
+	case BITXOR:
+		return s.symmetricDifference(other), true
+	}
+	return nil, false
+}
+
+// comparisons between sets are subset tests
+func (s *Set) compare(op int, other *Set) Value {
+	switch op {
+	case LESSTHAN:
+		return Bool(s.used < other.used && s.isSubset(other))
+	case LESSEQUAL:
+		return Bool(s.isSubset(other))
+	case GREATERTHAN:
+		return Bool(other.used < s.used && other.isSubset(s))
+	}
+	return Bool(other.isSubset(s))
+}
+
+/*##############
+### METHODS ###
+##############*/
+
+func init() {
+	SetType.New = newSet
+	SetType.Methods = map[string]MethodFunc{
+		"add": setAdd, "remove": setRemove, "discard": setDiscard, "pop": setPop,
+		"clear": setClear, "copy": setCopy, "update": setUpdate,
+		"union": setOperation("union"), "intersection": setOperation("intersection"),
+		"difference":           setOperation("difference"),
+		"symmetric_difference": setOperation("symmetric_difference"),
+		"issubset":             setTest("issubset"), "issuperset": setTest("issuperset"),
+		"isdisjoint": setTest("isdisjoint"),
+	}
+}
+
+// set(iterable=())
+func newSet(args []Value, kwargs map[string]Value) (Value, error) {
+	if err := noKeywords("set", kwargs); err != nil {
+		return nil, err
+	}
+	if err := atMostArgs("set", args, 1); err != nil {
+		return nil, err
+	}
+	s := &Set{}
+	if len(args) == 1 {
+		if err := s.Update(args[0]); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// check the single argument of a set method
+func oneArg(name string, args []Value, kwargs map[string]Value) error {
+	if err := noKeywords(name, kwargs); err != nil {
+		return err
+	}
+	return exactArgs(name, args, 1)
+}
+
+// set.add(elem)
+func setAdd(self Value, args []Value, kwargs map[string]Value) (Value, error) {
+	if err := oneArg("set.add", args, kwargs); err != nil {
+		return nil, err
+	}
+	return None, self.(*Set).Add(args[0])
+}
+
+// set.remove(elem), KeyError when it is missing
+func setRemove(self Value, args []Value, kwargs map[string]Value) (Value, error) {
+	if err := oneArg("set.remove", args, kwargs); err != nil {
+		return nil, err
+	}
+	found, err := self.(*Set).Discard(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, &Exception{Class: KeyError, Args: []Value{args[0]}}
+	}
+	return None, nil
+}
+
+// set.discard(elem)
+func setDiscard(self Value, args []Value, kwargs map[string]Value) (Value, error) {
+	if err := oneArg("set.discard", args, kwargs); err != nil {
+		return nil, err
+	}
+	_, err := self.(*Set).Discard(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return None, nil
+}
+
+// set.pop(), remove and return an item, the search start where the last
+// pop stopped
+func setPop(self Value, args []Value, kwargs map[string]Value) (Value, error) {
+	if err := noArgs("set.pop", args, kwargs); err != nil {
+		return nil, err
+	}
+	s := self.(*Set)
+	if s.used == 0 {
+		return nil, &Exception{Class: KeyError, Args: []Value{Str("pop from an empty set")}}
+	}
+	i := s.finger & s.mask()
+	for s.table[i].key == nil {
+		i = (i + 1) & s.mask()
+	}
+	key := s.table[i].key
+	s.table[i] = setEntry{dummy: true}
+	s.used--
+	s.finger = i + 1
+	return key, nil
+}
+
+// set.clear()
+func setClear(self Value, args []Value, kwargs map[string]Value) (Value, error) {
+	if err := noArgs("set.clear", args, kwargs); err != nil {
+		return nil, err
+	}
+	*self.(*Set) = Set{}
+	return None, nil
+}
+
+// set.copy(), a shallow copy
+func setCopy(self Value, args []Value, kwargs map[string]Value) (Value, error) {
+	if err := noArgs("set.copy", args, kwargs); err != nil {
+		return nil, err
+	}
+	return self.(*Set).copy(), nil
+}
+
+// set.update(*others)
+func setUpdate(self Value, args []Value, kwargs map[string]Value) (Value, error) {
+	if err := noKeywords("set.update", kwargs); err != nil {
+		return nil, err
+	}
+	for _, arg := range args {
+		if err := self.(*Set).Update(arg); err != nil {
+			return nil, err
+		}
+	}
+	return None, nil
+}
+
+// set.union(*others) and the other methods returning a new set, the
+// arguments can be any iterable
+func setOperation(name string) MethodFunc {
+	return func(self Value, args []Value, kwargs map[string]Value) (Value, error) {
+		if err := noKeywords("set."+name, kwargs); err != nil {
+			return nil, err
+		}
+		if name == "symmetric_difference" {
+			if err := exactArgs("set."+name, args, 1); err != nil {
+				return nil, err
+			}
+		}
+		result := self.(*Set).copy()
+		for _, arg := range args {
+			other, err := toSet(arg)
+			if err != nil {
+				return nil, err
+			}
+			switch name {
+			case "union":
+				result.merge(other)
+			case "intersection":
+				result = result.intersection(other)
+			case "difference":
+				result = result.difference(other)
+			case "symmetric_difference":
+				result = result.symmetricDifference(other)
+			}
+		}
+		return result, nil
+	}
+}
+
+// set.issubset(other), set.issuperset(other) and set.isdisjoint(other)
+func setTest(name string) MethodFunc {
+	return func(self Value, args []Value, kwargs map[string]Value) (Value, error) {
+		if err := oneArg("set."+name, args, kwargs); err != nil {
+			return nil, err
+		}
+		s := self.(*Set)
+		other, err := toSet(args[0])
+		if err != nil {
+			return nil, err
+		}
+		switch name {
+		case "issubset":
+			return Bool(s.isSubset(other)), nil
+		case "issuperset":
+			return Bool(other.isSubset(s)), nil
+		}
+		return Bool(s.intersection(other).used == 0), nil
+	}
+}
@@ -0,0 +1,140 @@
+package object
+
+import "strings"
+
+// immutable sequence, hashable when its items are
+type Tuple struct {
+	Items []Value
+}
+
+var TupleType = &Type{Name: "tuple"}
+
+func (t *Tuple) Type() *Type { return TupleType }
+func (t *Tuple) Truth() bool { return len(t.Items) > 0 }
+func (t *Tuple) Len() int    { return len(t.Items) }
+
+// (1, 2), a single item keep its comma: (1,)
+func (t *Tuple) Repr() string {
+	reprs := make([]string, len(t.Items))
+	for i, v := range t.Items {
+		reprs[i] = v.Repr()
+	}
+	if len(reprs) == 1 {
+		return "(" + reprs[0] + ",)"
+	}
+	return "(" + strings.Join(reprs, ", ") + ")"
+}
+
+func (t *Tuple) GetItem(key Value) (Value, error) {
+	if s, ok := key.(*Slice); ok {
+		start, step, count, err := s.Indices(len(t.Items))
+		if err != nil {
+			return nil, err
+		}
+		items := make([]Value, count)
+		for i := range items {
+			items[i] = t.Items[start+i*step]
+		}
+		return &Tuple{Items: items}, nil
+	}
+	if !isInt(key) {
+		return nil, Errorf(TypeError, "tuple indices must be integers or slices, not %s", key.Type().Name)
+	}
+	i := seqIndex(key, len(t.Items))
+	if i < 0 {
+		return nil, Errorf(IndexError, "tuple index out of range")
+	}
+	return t.Items[i], nil
+}
+
+// the xxHash based tuple hash of CPython
+func (t *Tuple) hash() (int64, error) {
+	const (
+		prime1 = 11400714785074694791
+		prime2 = 14029467366897019727
+		prime5 = 2870177450012600261
+	)
+	acc := uint64(prime5)
+	for _, item := range t.Items {
+		h, err := Hash(item)
+		if err != nil {
+			return 0, err
+		}
+		acc += uint64(h) * prime2
+		acc = acc<<31 | acc>>33
+		acc *= prime1
+	}
+	acc += uint64(len(t.Items)) ^ (prime5 ^ 3527539)
+	if int64(acc) == -1 {
+		return 1546275796, nil
+	}
+	return int64(acc), nil
+}
+
+// tuple(iterable=())
+func newTuple(args []Value, kwargs map[string]Value) (Value, error) {
+	if err := noKeywords("tuple", kwargs); err != nil {
+		return nil, err
+	}
+	if err := atMostArgs("tuple", args, 1); err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		return &Tuple{}, nil
+	}
+	if t, ok := args[0].(*Tuple); ok {
+		return t, nil
+	}
+	items, err := Iterate(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return &Tuple{Items: items}, nil
+}
+
+// new tuple holding the items of t n times, n <= 0 give an empty tuple
+func (t *Tuple) repeat(n int64) (*Tuple, error) {
+	if n == 1 {
+		return t, nil
+	}
+	l, err := (&List{Items: t.Items}).repeat(n)
+	if err != nil {
+		return nil, err
+	}
+	return &Tuple{Items: l.Items}, nil
+}
+
+/*##############
+### METHODS ###
+##############*/
+
+func init() {
+	TupleType.New = newTuple
+	TupleType.Methods = map[string]MethodFunc{"index": tupleIndex, "count": tupleCount}
+}
+
+// tuple.index(value, start=0, stop=len)
+func tupleIndex(self Value, args []Value, kwargs map[string]Value) (Value, error) {
+	if err := noKeywords("index", kwargs); err != nil {
+		return nil, err
+	}
+	i, err := indexOf(self.(*Tuple).Items, args)
+	if err != nil {
+		return nil, err
+	}
+	if i < 0 {
+		return nil, Errorf(ValueError, "tuple.index(x): x not in tuple")
+	}
+	return Int(i), nil
+}
+
+// tuple.count(value)
+func tupleCount(self Value, args []Value, kwargs map[string]Value) (Value, error) {
+	if err := noKeywords("tuple.count", kwargs); err != nil {
+		return nil, err
+	}
+	if err := exactArgs("tuple.count", args, 1); err != nil {
+		return nil, err
+	}
+	return countOf(self.(*Tuple).Items, args[0]), nil
+}
@@ -0,0 +1,403 @@
+// AST produced by the parser, each node keep the token it started with so
+// errors can report row and column
+package parser
+
+import (
+	"math/big"
+	"test1/object"
+	. "test1/tokenizer"
+)
+
+// every node can report the token where it start
+type Node interface {
+	Pos() Token
+}
+
+// statement nodes
+type Stmt interface {
+	Node
+	stmtNode()
+}
+
+// expression nodes
+type Expr interface {
+	Node
+	exprNode()
+}
+
+// <program> -> <stmt>* EOF
+type Module struct {
+	Body []Stmt
+}
+
+/*##################
+### STATEMENTS ###
+##################*/
+
+// (<targetlist> "=")+ <exprlist>, a target is a Name, a Subscript, an
+// Attribute or a TupleLit or ListLit of targets with at most one Starred.
+// The value is assigned to the targets from left to right
+type Assign struct {
+	Token   Token // the first token of the first target
+	Targets []Expr
+	Value   Expr
+}
+
+// "del" <target> ("," <target>)* [","]
+type Delete struct {
+	Token   Token
+	Targets []Expr
+}
+
+// "import" NAME ["as" NAME] ("," NAME ["as" NAME])*
+type Import struct {
+	Token Token
+	Names []*Alias
+}
+
+// module of an import statement and the name it is bound to, the name of
+// the module itself when there is no "as"
+type Alias struct {
+	Name   string
+	AsName string
+}
+
+// expression used as a statement, ex: f(1)
+type ExprStmt struct {
+	Token Token
+	X     Expr
+}
+
+type Pass struct {
+	Token Token
+}
+
+// "return" [<exprlist>], Value is nil for bare return
+type Return struct {
+	Token Token
+	Value Expr
+}
+
+// "global" NAME ("," NAME)*
+type Global struct {
+	Token Token
+	Names []string
+}
+
+// "nonlocal" NAME ("," NAME)*
+type Nonlocal struct {
+	Token Token
+	Names []string
+}
+
+// "if" <test> ":" <codeblock> ("elif" <test> ":" <codeblock>)* ["else" ":" <codeblock>],
+// Else is nil without else, an elif is an If alone in the Else of the one before
+type If struct {
+	Token Token
+	Cond  Expr
+	Body  []Stmt
+	Else  []Stmt
+}
+
+// "while" <test> ":" <codeblock> ["else" ":" <codeblock>], Else run when
+// the loop end without break
+type While struct {
+	Token Token
+	Cond  Expr
+	Body  []Stmt
+	Else  []Stmt
+}
+
+// "for" <targetlist> "in" <exprlist> ":" <codeblock> ["else" ":" <codeblock>],
+// Else run when the items are exhausted without break
+type For struct {
+	Token  Token
+	Target Expr
+	Iter   Expr
+	Body   []Stmt
+	Else   []Stmt
+}
+
+type Break struct {
+	Token Token
+}
+
+type Continue struct {
+	Token Token
+}
+
+// "def" NAME "(" [<parameters>] ")" ":" <codeblock>
+type FuncDef struct {
+	Token  Token
+	Name   string
+	Params *Params
+	Body   []Stmt
+}
+
+// parameters of a def or a lambda, the names are bound in the order of
+// object.Params
+type Params struct {
+	object.Params
+	Defaults   []Expr // defaults of the last positional parameters
+	KwDefaults []Expr // default of each keyword-only parameter, nil when it has none
+}
+
+// "try" ":" <codeblock> <exceptclause>* ["else" ":" <codeblock>] ["finally" ":" <codeblock>]
+// Else and Finally are nil when missing
+type Try struct {
+	Token    Token
+	Body     []Stmt
+	Handlers []*ExceptHandler
+	Else     []Stmt
+	Finally  []Stmt
+}
+
+// "except" [<test> ["as" NAME]] ":" <codeblock>, Type is nil for a bare
+// except and Name is "" without "as"
+type ExceptHandler struct {
+	Token Token
+	Type  Expr
+	Name  string
+	Body  []Stmt
+}
+
+// "raise" [<test> ["from" <test>]], Exc is nil for a bare raise
+type Raise struct {
+	Token Token
+	Exc   Expr
+	Cause Expr
+}
+
+/*###################
+### EXPRESSIONS ###
+###################*/
+
+type Name struct {
+	Token Token
+	Name  string
+}
+
+type IntLit struct {
+	Token Token
+	Value int
+	Big   *big.Int // the value when it is too large for Value, else nil
+}
+
+type FloatLit struct {
+	Token Token
+	Value float64
+}
+
+type StrLit struct {
+	Token Token
+	Value string
+}
+
+// True or False
+type BoolLit struct {
+	Token Token
+	Value bool
+}
+
+type NoneLit struct {
+	Token Token
+}
+
+// ("+" | "-" | "~") <factor> or "not" <nottest>, Op is the token category
+type UnaryOp struct {
+	Token Token
+	Op    int
+	X     Expr
+}
+
+// arithmetic, shift and bitwise operators, Op is the token category
+type BinaryOp struct {
+	Token Token // the operator token
+	Op    int
+	Left  Expr
+	Right Expr
+}
+
+// <test> ("and" | "or") <test>, Op is the token category. The value is
+// the operand that decide the truth of the whole, Right is not evaluated
+// when Left is enough
+type BoolOp struct {
+	Token Token // the operator token
+	Op    int
+	Left  Expr
+	Right Expr
+}
+
+// <ortest> "if" <ortest> "else" <test>, only one of Body and Else is evaluated
+type IfExp struct {
+	Token Token // the "if" token
+	Cond  Expr
+	Body  Expr
+	Else  Expr
+}
+
+// <bitor> (<compop> <bitor>)+, Ops are the token categories, IN for "in",
+// NOTIN for "not in" and ISNOT for "is not". a < b < c is a < b and b < c
+// with b evaluated once
+type Compare struct {
+	Token       Token // the first operator token
+	Left        Expr
+	Ops         []int
+	Comparators []Expr
+}
+
+// <primary> "(" [<argument> ("," <argument>)* [","]] ")", Args may hold
+// Starred arguments
+type Call struct {
+	Token    Token // the "(" token
+	Func     Expr
+	Args     []Expr
+	Keywords []*Keyword
+}
+
+// NAME "=" <test> argument of a call, or "**" <test> when Name is ""
+type Keyword struct {
+	Token Token // the name or the "**" token
+	Name  string
+	Value Expr
+}
+
+// "[" [<starexpr> ("," <starexpr>)* [","]] "]"
+type ListLit struct {
+	Token Token
+	Elts  []Expr
+}
+
+// <starexpr> ("," <starexpr>)+ [","] or "(" ")", Token is the first token
+// of the first item
+type TupleLit struct {
+	Token Token
+	Elts  []Expr
+}
+
+// "{" <starexpr> ("," <starexpr>)* [","] "}"
+type SetLit struct {
+	Token Token
+	Elts  []Expr
+}
+
+// "lambda" [<parameters>] ":" <test>
+type Lambda struct {
+	Token  Token
+	Params *Params
+	Body   Expr
+}
+
+// "*" <bitor>, an item unpacked in a display or a call, or a target taking
+// the rest of the items
+type Starred struct {
+	Token Token
+	X     Expr
+}
+
+// "{" [<test> ":" <test> ("," <test> ":" <test>)* [","]] "}"
+type DictLit struct {
+	Token  Token
+	Keys   []Expr
+	Values []Expr
+}
+
+// <expr> "[" <subscript> "]", Index is a Slice for x[a:b:c]
+type Subscript struct {
+	Token Token // the "[" token
+	X     Expr
+	Index Expr
+}
+
+// [<test>] ":" [<test>] [":" [<test>]], the missing parts are nil
+type Slice struct {
+	Token Token // the first ":" token
+	Lower Expr
+	Upper Expr
+	Step  Expr
+}
+
+// <expr> "." NAME
+type Attribute struct {
+	Token Token // the "." token
+	X     Expr
+	Name  string
+}
+
+func (s *Assign) Pos() Token   { return s.Token }
+func (s *Import) Pos() Token   { return s.Token }
+func (s *ExprStmt) Pos() Token { return s.Token }
+func (s *Pass) Pos() Token     { return s.Token }
+func (s *Return) Pos() Token   { return s.Token }
+func (s *Global) Pos() Token   { return s.Token }
+func (s *Nonlocal) Pos() Token { return s.Token }
+func (s *If) Pos() Token       { return s.Token }
+func (s *While) Pos() Token    { return s.Token }
+func (s *For) Pos() Token      { return s.Token }
+func (s *Break) Pos() Token    { return s.Token }
+func (s *Continue) Pos() Token { return s.Token }
+func (s *FuncDef) Pos() Token  { return s.Token }
+func (s *Try) Pos() Token      { return s.Token }
+func (s *Raise) Pos() Token    { return s.Token }
+func (s *Delete) Pos() Token   { return s.Token }
+
+func (*Assign) stmtNode()   {}
+func (*Import) stmtNode()   {}
+func (*ExprStmt) stmtNode() {}
+func (*Pass) stmtNode()     {}
+func (*Return) stmtNode()   {}
+func (*Global) stmtNode()   {}
+func (*Nonlocal) stmtNode() {}
+func (*If) stmtNode()       {}
+func (*While) stmtNode()    {}
+func (*For) stmtNode()      {}
+func (*Break) stmtNode()    {}
+func (*Continue) stmtNode() {}
+func (*FuncDef) stmtNode()  {}
+func (*Try) stmtNode()      {}
+func (*Raise) stmtNode()    {}
+func (*Delete) stmtNode()   {}
+
+func (e *Name) Pos() Token      { return e.Token }
+func (e *IntLit) Pos() Token    { return e.Token }
+func (e *FloatLit) Pos() Token  { return e.Token }
+func (e *StrLit) Pos() Token    { return e.Token }
+func (e *BoolLit) Pos() Token   { return e.Token }
+func (e *NoneLit) Pos() Token   { return e.Token }
+func (e *UnaryOp) Pos() Token   { return e.Token }
+func (e *BinaryOp) Pos() Token  { return e.Token }
+func (e *BoolOp) Pos() Token    { return e.Token }
+func (e *IfExp) Pos() Token     { return e.Token }
+func (e *Compare) Pos() Token   { return e.Token }
+func (e *Call) Pos() Token      { return e.Token }
+func (e *ListLit) Pos() Token   { return e.Token }
+func (e *TupleLit) Pos() Token  { return e.Token }
+func (e *SetLit) Pos() Token    { return e.Token }
+func (e *Starred) Pos() Token   { return e.Token }
+func (e *Lambda) Pos() Token    { return e.Token }
+func (e *DictLit) Pos() Token   { return e.Token }
+func (e *Subscript) Pos() Token { return e.Token }
+func (e *Slice) Pos() Token     { return e.Token }
+func (e *Attribute) Pos() Token { return e.Token }
+
+func (*Name) exprNode()      {}
+func (*IntLit) exprNode()    {}
+func (*FloatLit) exprNode()  {}
+func (*StrLit) exprNode()    {}
+func (*BoolLit) exprNode()   {}
+func (*NoneLit) exprNode()   {}
+func (*UnaryOp) exprNode()   {}
+func (*BinaryOp) exprNode()  {}
+func (*BoolOp) exprNode()    {}
+func (*IfExp) exprNode()     {}
+func (*Compare) exprNode()   {}
+func (*Call) exprNode()      {}
+func (*ListLit) exprNode()   {}
+func (*TupleLit) exprNode()  {}
+func (*SetLit) exprNode()    {}
+func (*Starred) exprNode()   {}
+func (*Lambda) exprNode()    {}
+func (*DictLit) exprNode()   {}
+func (*Subscript) exprNode() {}
+func (*Slice) exprNode()     {}
+func (*Attribute) exprNode() {}
@@ -0,0 +1,1097 @@
+// Recursive descent parser that turn the tokenlist into an AST
+package parser
+
+import (
+	"math/big"
+	"strconv"
+	"test1/object"
+	. "test1/tokenizer"
+)
+
+type Parser struct {
+	lexer *Lexer
+	token Token // current token
+	loops int   // loops around the current statement in the function being parsed
+}
+
+// wrap errors so Parse can tell them apart from other panics
+type bailout struct {
+	err *object.Exception
+}
+
+// parse a whole program, reading tokens from the lexer until EOF, errors
+// are SyntaxError or IndentationError exceptions
+func Parse(lexer *Lexer) (module *Module, err error) {
+	p := &Parser{lexer: lexer}
+	defer func() {
+		if r := recover(); r != nil {
+			b, ok := r.(bailout)
+			if !ok {
+				panic(r)
+			}
+			module, err = nil, b.err
+		}
+	}()
+	p.advance()
+	return p.program(), nil
+}
+
+// parse a single expression, ex: the source given to eval
+func ParseExpr(lexer *Lexer) (expr Expr, err error) {
+	p := &Parser{lexer: lexer}
+	defer func() {
+		if r := recover(); r != nil {
+			b, ok := r.(bailout)
+			if !ok {
+				panic(r)
+			}
+			expr, err = nil, b.err
+		}
+	}()
+	p.advance()
+	expr = p.valuelist()
+	for p.token.Category == NEWLINE {
+		p.advance()
+	}
+	if p.token.Category != EOF {
+		p.errorf("invalid syntax")
+	}
+	return expr, nil
+}
+
+// stop parsing with a SyntaxError at the current token, Parse recover the
+// error and return it
+func (p *Parser) errorf(format string, args ...interface{}) {
+	p.fail(object.SyntaxError, p.token, format, args...)
+}
+
+func (p *Parser) fail(cls *object.Type, at Token, format string, args ...interface{}) {
+	exc := object.Errorf(cls, format, args...)
+	exc.Row, exc.Column = at.Row, at.Column
+	panic(bailout{exc})
+}
+
+// turn an error of the lexer into a SyntaxError
+func (p *Parser) scanError(err error) {
+	e := err.(*ScanError)
+	cls := object.SyntaxError
+	if e.Indentation {
+		cls = object.IndentationError
+	}
+	p.fail(cls, Token{Row: e.Row, Column: e.Column}, "%s", e.Msg)
+}
+
+// check if the current token has the same category as expectedCategory,
+// if not then the grammar is invalid
+func (p *Parser) consume(expectedCategory int) Token {
+	if p.token.Category != expectedCategory {
+		p.errorf("expecting %s, got %s", Describe(expectedCategory), p.describeToken())
+	}
+	save := p.token
+	p.advance()
+	return save
+}
+
+// advance to the next token and save current token
+func (p *Parser) advance() {
+	t, err := p.lexer.Next()
+	if err != nil {
+		p.scanError(err)
+	}
+	p.token = t
+}
+
+// category of the token after the current one
+func (p *Parser) peek() int {
+	t, err := p.lexer.Peek()
+	if err != nil {
+		p.scanError(err)
+	}
+	return t.Category
+}
+
+func (p *Parser) describeToken() string {
+	if p.token.Lexeme == "" || p.token.Category == NEWLINE {
+		return Describe(p.token.Category)
+	}
+	return "'" + p.token.Lexeme + "'"
+}
+
+// <program> -> <stmt>* EOF
+func (p *Parser) program() *Module {
+	m := &Module{}
+	for p.token.Category != EOF {
+		m.Body = append(m.Body, p.stmt())
+	}
+	return m
+}
+
+// <stmt> -> <simplestmt> NEWLINE | <compoundstmt>
+// *compoundstmt does not have NEWLINE because <codeblock> already consume it
+func (p *Parser) stmt() Stmt {
+	switch p.token.Category {
+	case INDENT:
+		p.fail(object.IndentationError, p.token, "unexpected indent")
+	case IF, WHILE, FOR, DEF, TRY:
+		return p.compoundstmt()
+	}
+	s := p.simplestmt()
+	p.consume(NEWLINE)
+	return s
+}
+
+// <simplestmt> -> <assignmentstmt> | <importstmt> | <passstmt> | <returnstmt>
+//
+//	| <globalstmt> | <nonlocalstmt> | <raisestmt> | <delstmt> | <breakstmt>
+//	| <continuestmt> | <exprlist>
+func (p *Parser) simplestmt() Stmt {
+	switch p.token.Category {
+	case IMPORT:
+		return p.importstmt()
+	case PASS:
+		return p.passstmt()
+	case RETURN:
+		return p.returnstmt()
+	case GLOBAL:
+		return p.globalstmt()
+	case NONLOCAL:
+		return p.nonlocalstmt()
+	case RAISE:
+		return p.raisestmt()
+	case DEL:
+		return p.delstmt()
+	case BREAK:
+		return p.breakstmt()
+	case CONTINUE:
+		return p.continuestmt()
+	}
+	start := p.token
+	x := p.exprlist()
+	if p.token.Category == ASSIGNOP {
+		return p.assignmentstmt(start, x)
+	}
+	p.checkValue(start, x)
+	return &ExprStmt{Token: start, X: x}
+}
+
+// <compoundstmt> -> <ifstmt> | <whilestmt> | <forstmt> | <defstmt> | <trystmt>
+func (p *Parser) compoundstmt() Stmt {
+	switch p.token.Category {
+	case IF:
+		return p.ifstmt()
+	case WHILE:
+		return p.whilestmt()
+	case FOR:
+		return p.forstmt()
+	case DEF:
+		return p.defstmt()
+	case TRY:
+		return p.trystmt()
+	}
+	p.errorf("expecting 'if', 'while', 'for', 'def' or 'try', got %s", p.describeToken())
+	return nil
+}
+
+// <assignmentstmt> -> (<targetlist> "=")+ <exprlist>
+// <targetlist> -> <target> ("," <target>)* [","]
+// <target> -> NAME | <factor> "[" <subscript> "]" | <factor> "." NAME
+//
+//	| "(" [<targetlist>] ")" | "[" [<targetlist>] "]" | "*" <target>
+//
+// *the targets are parsed as expressions then checked, start is the first token
+func (p *Parser) assignmentstmt(start Token, target Expr) Stmt {
+	s := &Assign{Token: start}
+	for p.token.Category == ASSIGNOP {
+		if msg := targetError(target, false); msg != "" {
+			p.errorf("%s", msg)
+		}
+		s.Targets = append(s.Targets, target)
+		p.advance()
+		start = p.token
+		target = p.exprlist()
+	}
+	p.checkValue(start, target)
+	s.Value = target
+	return s
+}
+
+// <delstmt> -> "del" <targetlist>
+func (p *Parser) delstmt() Stmt {
+	s := &Delete{Token: p.consume(DEL)}
+	at := p.token
+	x := p.exprlist()
+	if msg := targetError(x, true); msg != "" {
+		p.fail(object.SyntaxError, at, "%s", msg)
+	}
+	// del a, b delete each target
+	if t, ok := x.(*TupleLit); ok {
+		s.Targets = t.Elts
+	} else {
+		s.Targets = []Expr{x}
+	}
+	return s
+}
+
+// the error when x can not be assigned, or deleted when del is true, ""
+// when it can. Names, items and attributes can, and lists and tuples of them
+func targetError(x Expr, del bool) string {
+	switch t := x.(type) {
+	case *Name, *Subscript, *Attribute:
+		return ""
+	case *TupleLit:
+		return targetListError(t.Elts, del)
+	case *ListLit:
+		return targetListError(t.Elts, del)
+	case *Starred:
+		if del {
+			return "cannot delete starred"
+		}
+		return "starred assignment target must be in a list or tuple"
+	}
+	if del {
+		return "cannot delete expression"
+	}
+	return "cannot assign to expression"
+}
+
+// a list of targets can have one starred target, not when it is deleted
+func targetListError(elts []Expr, del bool) string {
+	starred := false
+	for _, elt := range elts {
+		if s, ok := elt.(*Starred); ok && !del {
+			if starred {
+				return "multiple starred expressions in assignment"
+			}
+			starred = true
+			elt = s.X
+		}
+		if msg := targetError(elt, del); msg != "" {
+			return msg
+		}
+	}
+	return ""
+}
+
+// a starred expression can only be an item of a display, start is the
+// first token of x
+func (p *Parser) checkValue(start Token, x Expr) {
+	if _, ok := x.(*Starred); ok {
+		p.fail(object.SyntaxError, start, "can't use starred expression here")
+	}
+}
+
+// <importstmt> -> "import" NAME ["as" NAME] ("," NAME ["as" NAME])*
+func (p *Parser) importstmt() Stmt {
+	s := &Import{Token: p.consume(IMPORT)}
+	for {
+		alias := &Alias{Name: p.consume(NAME).Lexeme}
+		alias.AsName = alias.Name
+		if p.token.Category == AS {
+			p.advance()
+			alias.AsName = p.consume(NAME).Lexeme
+		}
+		s.Names = append(s.Names, alias)
+		if p.token.Category != COMMA {
+			return s
+		}
+		p.advance()
+	}
+}
+
+// <passstmt> -> "pass"
+func (p *Parser) passstmt() Stmt {
+	return &Pass{Token: p.consume(PASS)}
+}
+
+// <returnstmt> -> "return" [<exprlist>]
+func (p *Parser) returnstmt() Stmt {
+	s := &Return{Token: p.consume(RETURN)}
+	if p.token.Category != NEWLINE {
+		s.Value = p.valuelist()
+	}
+	return s
+}
+
+// <breakstmt> -> "break", only inside a loop
+func (p *Parser) breakstmt() Stmt {
+	if p.loops == 0 {
+		p.errorf("'break' outside loop")
+	}
+	return &Break{Token: p.consume(BREAK)}
+}
+
+// <continuestmt> -> "continue", only inside a loop
+func (p *Parser) continuestmt() Stmt {
+	if p.loops == 0 {
+		p.errorf("'continue' not properly in loop")
+	}
+	return &Continue{Token: p.consume(CONTINUE)}
+}
+
+// <globalstmt> -> "global" NAME ("," NAME)*
+func (p *Parser) globalstmt() Stmt {
+	s := &Global{Token: p.consume(GLOBAL)}
+	s.Names = p.namelist()
+	return s
+}
+
+// <nonlocalstmt> -> "nonlocal" NAME ("," NAME)*
+func (p *Parser) nonlocalstmt() Stmt {
+	s := &Nonlocal{Token: p.consume(NONLOCAL)}
+	s.Names = p.namelist()
+	return s
+}
+
+// <raisestmt> -> "raise" [<test> ["from" <test>]]
+func (p *Parser) raisestmt() Stmt {
+	s := &Raise{Token: p.consume(RAISE)}
+	if p.token.Category != NEWLINE {
+		s.Exc = p.test()
+		if p.token.Category == FROM {
+			p.advance()
+			s.Cause = p.test()
+		}
+	}
+	return s
+}
+
+// NAME ("," NAME)*
+func (p *Parser) namelist() []string {
+	names := []string{p.consume(NAME).Lexeme}
+	for p.token.Category == COMMA {
+		p.advance()
+		names = append(names, p.consume(NAME).Lexeme)
+	}
+	return names
+}
+
+// <ifstmt> -> "if" <test> ":" <codeblock> ("elif" <test> ":" <codeblock>)*
+//
+//	["else" ":" <codeblock>]
+//
+// *the if and each elif consume their token, then the rest is the else
+func (p *Parser) ifstmt() Stmt {
+	s := &If{Token: p.token}
+	p.advance()
+	s.Cond = p.test()
+	p.consume(COLON)
+	s.Body = p.codeblock()
+	if p.token.Category == ELIF {
+		s.Else = []Stmt{p.ifstmt()}
+	} else if p.token.Category == ELSE {
+		p.advance()
+		p.consume(COLON)
+		s.Else = p.codeblock()
+	}
+	return s
+}
+
+// <whilestmt> -> "while" <test> ":" <codeblock> ["else" ":" <codeblock>]
+func (p *Parser) whilestmt() Stmt {
+	s := &While{Token: p.consume(WHILE)}
+	s.Cond = p.test()
+	p.consume(COLON)
+	s.Body, s.Else = p.loopbody()
+	return s
+}
+
+// <forstmt> -> "for" <targetlist> "in" <exprlist> ":" <codeblock> ["else" ":" <codeblock>]
+func (p *Parser) forstmt() Stmt {
+	s := &For{Token: p.consume(FOR)}
+	at := p.token
+	s.Target = p.targetlist()
+	if msg := targetError(s.Target, false); msg != "" {
+		p.fail(object.SyntaxError, at, "%s", msg)
+	}
+	p.consume(IN)
+	s.Iter = p.valuelist()
+	p.consume(COLON)
+	s.Body, s.Else = p.loopbody()
+	return s
+}
+
+// the body of a loop, where break and continue are allowed, and its else
+// block, where they belong to the enclosing loop
+func (p *Parser) loopbody() (body, orelse []Stmt) {
+	p.loops++
+	body = p.codeblock()
+	p.loops--
+	if p.token.Category == ELSE {
+		p.advance()
+		p.consume(COLON)
+		orelse = p.codeblock()
+	}
+	return body, orelse
+}
+
+// <defstmt> -> "def" NAME "(" [<parameters>] ")" ":" <codeblock>
+func (p *Parser) defstmt() Stmt {
+	s := &FuncDef{Token: p.consume(DEF)}
+	s.Name = p.consume(NAME).Lexeme
+	p.consume(LEFTPARENT)
+	s.Params = p.parameters(RIGHTPARENT)
+	p.consume(RIGHTPARENT)
+	p.consume(COLON)
+	// the loops around the def do not extend into its body
+	loops := p.loops
+	p.loops = 0
+	s.Body = p.codeblock()
+	p.loops = loops
+	return s
+}
+
+/*
+<parameters> -> <parameter> ("," <parameter>)* [","]
+<parameter> -> NAME ["=" <test>] | "/" | "*" [NAME] | "**" NAME
+*the parameters before "/" are positional-only, the ones after "*" are
+keyword-only. The list end before the closing token, which is not consumed
+*/
+func (p *Parser) parameters(closing int) *Params {
+	params := &Params{}
+	seen := map[string]bool{}
+	name := func() string {
+		tok := p.consume(NAME)
+		if seen[tok.Lexeme] {
+			p.fail(object.SyntaxError, tok, "duplicate argument '%s' in function definition", tok.Lexeme)
+		}
+		seen[tok.Lexeme] = true
+		return tok.Lexeme
+	}
+	var slash, star, bareStar bool
+	for p.token.Category != closing {
+		if params.KwArg != "" {
+			p.errorf("arguments cannot follow var-keyword argument")
+		}
+		switch p.token.Category {
+		case DIV:
+			switch {
+			case slash:
+				p.errorf("/ may appear only once")
+			case star:
+				p.errorf("/ must be ahead of *")
+			case len(params.Names) == 0:
+				p.errorf("at least one argument must precede /")
+			}
+			p.advance()
+			slash = true
+			params.PosOnly = len(params.Names)
+		case TIMES:
+			if star {
+				p.errorf("* argument may appear only once")
+			}
+			p.advance()
+			star = true
+			if p.token.Category == NAME {
+				params.VarArg = name()
+				if p.token.Category == ASSIGNOP {
+					p.errorf("var-positional argument cannot have default value")
+				}
+			} else {
+				bareStar = true
+			}
+		case POWER:
+			if bareStar && len(params.Names) == params.Argcount {
+				p.errorf("named arguments must follow bare *")
+			}
+			p.advance()
+			params.KwArg = name()
+			if p.token.Category == ASSIGNOP {
+				p.errorf("var-keyword argument cannot have default value")
+			}
+		default:
+			start := p.token
+			params.Names = append(params.Names, name())
+			var def Expr
+			if p.token.Category == ASSIGNOP {
+				p.advance()
+				def = p.test()
+			}
+			if star {
+				params.KwDefaults = append(params.KwDefaults, def)
+				break
+			}
+			if def == nil && len(params.Defaults) > 0 {
+				p.fail(object.SyntaxError, start, "non-default argument follows default argument")
+			}
+			if def != nil {
+				params.Defaults = append(params.Defaults, def)
+			}
+			params.Argcount++
+		}
+		if p.token.Category != COMMA {
+			break
+		}
+		p.advance()
+	}
+	if bareStar && len(params.Names) == params.Argcount {
+		p.errorf("named arguments must follow bare *")
+	}
+	return params
+}
+
+// <trystmt> -> "try" ":" <codeblock> <exceptclause>* ["else" ":" <codeblock>]
+//
+//	["finally" ":" <codeblock>]
+//
+// there must be at least one except or the finally, and else need an except
+func (p *Parser) trystmt() Stmt {
+	s := &Try{Token: p.consume(TRY)}
+	p.consume(COLON)
+	s.Body = p.codeblock()
+	for p.token.Category == EXCEPT {
+		if n := len(s.Handlers); n > 0 && s.Handlers[n-1].Type == nil {
+			p.fail(object.SyntaxError, s.Handlers[n-1].Token, "default 'except:' must be last")
+		}
+		s.Handlers = append(s.Handlers, p.exceptclause())
+	}
+	if p.token.Category == ELSE && len(s.Handlers) > 0 {
+		p.advance()
+		p.consume(COLON)
+		s.Else = p.codeblock()
+	}
+	if p.token.Category == FINALLY {
+		p.advance()
+		p.consume(COLON)
+		s.Finally = p.codeblock()
+	}
+	if len(s.Handlers) == 0 && s.Finally == nil {
+		p.errorf("expected 'except' or 'finally' block")
+	}
+	return s
+}
+
+// <exceptclause> -> "except" [<test> ["as" NAME]] ":" <codeblock>
+func (p *Parser) exceptclause() *ExceptHandler {
+	h := &ExceptHandler{Token: p.consume(EXCEPT)}
+	if p.token.Category != COLON {
+		h.Type = p.test()
+		if p.token.Category == AS {
+			p.advance()
+			h.Name = p.consume(NAME).Lexeme
+		}
+	}
+	p.consume(COLON)
+	h.Body = p.codeblock()
+	return h
+}
+
+// <codeblock> -> NEWLINE INDENT <stmt>+ DEDENT | <simplestmt> NEWLINE
+func (p *Parser) codeblock() []Stmt {
+	if p.token.Category != NEWLINE {
+		s := p.simplestmt()
+		p.consume(NEWLINE)
+		return []Stmt{s}
+	}
+	p.consume(NEWLINE)
+	if p.token.Category != INDENT {
+		p.fail(object.IndentationError, p.token, "expected an indented block")
+	}
+	p.advance()
+	body := []Stmt{p.stmt()} // must have at least 1 stmt
+	for p.token.Category != DEDENT && p.token.Category != EOF {
+		body = append(body, p.stmt())
+	}
+	p.consume(DEDENT)
+	return body
+}
+
+// <exprlist> -> <starexpr> ("," <starexpr>)* [","]
+// *a single expression without comma is returned as it is, else a TupleLit
+func (p *Parser) exprlist() Expr {
+	return p.itemlist(p.starexpr)
+}
+
+// the <targetlist> of a for statement, its items stop before "in" so they
+// are parsed without comparisons
+func (p *Parser) targetlist() Expr {
+	return p.itemlist(p.startarget)
+}
+
+// items parsed by item and separated by commas, a single item without
+// comma is returned as it is, else a TupleLit
+func (p *Parser) itemlist(item func() Expr) Expr {
+	start := p.token
+	x := item()
+	if p.token.Category != COMMA {
+		return x
+	}
+	t := &TupleLit{Token: start, Elts: []Expr{x}}
+	for p.token.Category == COMMA {
+		p.advance()
+		if !startsExpr(p.token.Category) {
+			break
+		}
+		t.Elts = append(t.Elts, item())
+	}
+	return t
+}
+
+// an <exprlist> used as a value, it can not be a lone starred expression
+func (p *Parser) valuelist() Expr {
+	start := p.token
+	x := p.exprlist()
+	p.checkValue(start, x)
+	return x
+}
+
+// true if a token of this category can start an expression, a comma
+// followed by anything else end an <exprlist>
+func startsExpr(category int) bool {
+	switch category {
+	case NAME, UNSIGNEDINT, UNSIGNEDFLOAT, STRING, TRUE, FALSE, NONE,
+		LEFTPARENT, LEFTBRACKET, LEFTBRACE, PLUS, MINUS, TIMES, NOT, INVERT, LAMBDA:
+		return true
+	}
+	return false
+}
+
+// <starexpr> -> "*" <bitor> | <test>
+func (p *Parser) starexpr() Expr {
+	if p.token.Category == TIMES {
+		star := p.consume(TIMES)
+		return &Starred{Token: star, X: p.bitor()}
+	}
+	return p.test()
+}
+
+// "*" <bitor> | <bitor>, an item of a <targetlist>
+func (p *Parser) startarget() Expr {
+	if p.token.Category == TIMES {
+		star := p.consume(TIMES)
+		return &Starred{Token: star, X: p.bitor()}
+	}
+	return p.bitor()
+}
+
+// <test> -> <ortest> ["if" <ortest> "else" <test>] | <lambdef>
+func (p *Parser) test() Expr {
+	if p.token.Category == LAMBDA {
+		return p.lambdef()
+	}
+	x := p.ortest()
+	if p.token.Category != IF {
+		return x
+	}
+	e := &IfExp{Token: p.consume(IF), Body: x}
+	e.Cond = p.ortest()
+	if p.token.Category != ELSE {
+		p.errorf("expected 'else' after 'if' expression")
+	}
+	p.advance()
+	e.Else = p.test()
+	return e
+}
+
+// <lambdef> -> "lambda" [<parameters>] ":" <test>
+func (p *Parser) lambdef() Expr {
+	x := &Lambda{Token: p.consume(LAMBDA)}
+	x.Params = p.parameters(COLON)
+	p.consume(COLON)
+	x.Body = p.test()
+	return x
+}
+
+// <ortest> -> <andtest> ("or" <andtest>)*
+func (p *Parser) ortest() Expr {
+	left := p.andtest()
+	for p.token.Category == OR {
+		op := p.token
+		p.advance()
+		left = &BoolOp{Token: op, Op: op.Category, Left: left, Right: p.andtest()}
+	}
+	return left
+}
+
+// <andtest> -> <nottest> ("and" <nottest>)*
+func (p *Parser) andtest() Expr {
+	left := p.nottest()
+	for p.token.Category == AND {
+		op := p.token
+		p.advance()
+		left = &BoolOp{Token: op, Op: op.Category, Left: left, Right: p.nottest()}
+	}
+	return left
+}
+
+// <nottest> -> "not" <nottest> | <relexpr>
+func (p *Parser) nottest() Expr {
+	if p.token.Category == NOT {
+		op := p.token
+		p.advance()
+		return &UnaryOp{Token: op, Op: op.Category, X: p.nottest()}
+	}
+	return p.relexpr()
+}
+
+// <relexpr> -> <bitor> (<compop> <bitor>)*
+// <compop> -> CONDITIONALOP | "in" | "not" "in" | "is" ["not"]
+func (p *Parser) relexpr() Expr {
+	left := p.bitor()
+	var cmp *Compare
+	for {
+		tok, op := p.token, p.token.Category
+		switch op {
+		case EQUAL, NOTEQUAL, LESSTHAN, LESSEQUAL, GREATERTHAN, GREATEREQUAL, IN:
+		case NOT:
+			p.advance()
+			if p.token.Category != IN {
+				p.errorf("invalid syntax")
+			}
+			op = NOTIN
+		case IS:
+			if p.peek() == NOT {
+				p.advance()
+				op = ISNOT
+			}
+		default:
+			if cmp == nil {
+				return left
+			}
+			return cmp
+		}
+		if cmp == nil {
+			cmp = &Compare{Token: tok, Left: left}
+		}
+		p.advance()
+		cmp.Ops = append(cmp.Ops, op)
+		cmp.Comparators = append(cmp.Comparators, p.bitor())
+	}
+}
+
+// <bitor> -> <bitxor> ("|" <bitxor>)*
+func (p *Parser) bitor() Expr {
+	left := p.bitxor()
+	for p.token.Category == BITOR {
+		op := p.token
+		p.advance()
+		left = &BinaryOp{Token: op, Op: op.Category, Left: left, Right: p.bitxor()}
+	}
+	return left
+}
+
+// <bitxor> -> <bitand> ("^" <bitand>)*
+func (p *Parser) bitxor() Expr {
+	left := p.bitand()
+	for p.token.Category == BITXOR {
+		op := p.token
+		p.advance()
+		left = &BinaryOp{Token: op, Op: op.Category, Left: left, Right: p.bitand()}
+	}
+	return left
+}
+
+// <bitand> -> <shiftexpr> ("&" <shiftexpr>)*
+func (p *Parser) bitand() Expr {
+	left := p.shiftexpr()
+	for p.token.Category == BITAND {
+		op := p.token
+		p.advance()
+		left = &BinaryOp{Token: op, Op: op.Category, Left: left, Right: p.shiftexpr()}
+	}
+	return left
+}
+
+// <shiftexpr> -> <expr> (("<<" | ">>") <expr>)*
+func (p *Parser) shiftexpr() Expr {
+	left := p.expr()
+	for p.token.Category == LSHIFT || p.token.Category == RSHIFT {
+		op := p.token
+		p.advance()
+		left = &BinaryOp{Token: op, Op: op.Category, Left: left, Right: p.expr()}
+	}
+	return left
+}
+
+// <expr> -> <term> (("+" | "-") <term>)*
+func (p *Parser) expr() Expr {
+	left := p.term()
+	for p.token.Category == PLUS || p.token.Category == MINUS {
+		op := p.token
+		p.advance()
+		left = &BinaryOp{Token: op, Op: op.Category, Left: left, Right: p.term()}
+	}
+	return left
+}
+
+// <term> -> <factor> (("*" | "/" | "//" | "%") <factor>)*
+func (p *Parser) term() Expr {
+	left := p.factor()
+	for {
+		switch p.token.Category {
+		case TIMES, DIV, FLOORDIV, MOD:
+			op := p.token
+			p.advance()
+			left = &BinaryOp{Token: op, Op: op.Category, Left: left, Right: p.factor()}
+		default:
+			return left
+		}
+	}
+}
+
+// <factor> -> ("+" | "-" | "~") <factor> | <power>
+func (p *Parser) factor() Expr {
+	switch p.token.Category {
+	case PLUS, MINUS, INVERT:
+		op := p.token
+		p.advance()
+		return &UnaryOp{Token: op, Op: op.Category, X: p.factor()}
+	}
+	return p.power()
+}
+
+/*
+<power> -> <primary> ["**" <factor>]
+*the right operand is a factor so ** is right associative and bind tighter
+than a unary operator on its left: -2 ** 2 is -(2 ** 2)
+*/
+func (p *Parser) power() Expr {
+	x := p.primary()
+	if p.token.Category == POWER {
+		op := p.token
+		p.advance()
+		return &BinaryOp{Token: op, Op: op.Category, Left: x, Right: p.factor()}
+	}
+	return x
+}
+
+/*
+<primary> -> <atom> <trailer>*
+<trailer> -> <functioncall>
+<trailer> -> "[" <subscript> "]"
+<trailer> -> "." NAME
+*/
+func (p *Parser) primary() Expr {
+	x := p.atom()
+	for {
+		switch p.token.Category {
+		case LEFTPARENT:
+			x = p.functioncall(x)
+		case LEFTBRACKET:
+			x = p.subscript(x)
+		case DOT:
+			dot := p.consume(DOT)
+			x = &Attribute{Token: dot, X: x, Name: p.consume(NAME).Lexeme}
+		default:
+			return x
+		}
+	}
+}
+
+/*
+<functioncall> -> "(" [<argument> ("," <argument>)* [","]] ")"
+<argument> -> <test> | NAME "=" <test> | "*" <test> | "**" <test>
+*the positional arguments come before the keyword ones, "*" arguments can
+also follow keyword arguments but not "**" ones
+*/
+func (p *Parser) functioncall(fn Expr) Expr {
+	call := &Call{Token: p.consume(LEFTPARENT), Func: fn}
+	unpacking := false // a "**" argument was found
+	for p.token.Category != RIGHTPARENT {
+		start := p.token
+		if start.Category == POWER {
+			p.advance()
+			call.Keywords = append(call.Keywords, &Keyword{Token: start, Value: p.test()})
+			unpacking = true
+			if p.token.Category != COMMA {
+				break
+			}
+			p.advance()
+			continue
+		}
+		if start.Category == TIMES {
+			if unpacking {
+				p.fail(object.SyntaxError, start, "iterable argument unpacking follows keyword argument unpacking")
+			}
+			p.advance()
+			call.Args = append(call.Args, &Starred{Token: start, X: p.test()})
+			if p.token.Category != COMMA {
+				break
+			}
+			p.advance()
+			continue
+		}
+		arg := p.test()
+		if p.token.Category == ASSIGNOP {
+			name, ok := arg.(*Name)
+			if !ok {
+				p.fail(object.SyntaxError, start, "expression cannot contain assignment, perhaps you meant \"==\"?")
+			}
+			p.advance()
+			for _, k := range call.Keywords {
+				if k.Name == name.Name {
+					p.fail(object.SyntaxError, start, "keyword argument repeated: %s", name.Name)
+				}
+			}
+			call.Keywords = append(call.Keywords, &Keyword{Token: start, Name: name.Name, Value: p.test()})
+		} else {
+			if unpacking {
+				p.fail(object.SyntaxError, start, "positional argument follows keyword argument unpacking")
+			}
+			if len(call.Keywords) > 0 {
+				p.fail(object.SyntaxError, start, "positional argument follows keyword argument")
+			}
+			call.Args = append(call.Args, arg)
+		}
+		if p.token.Category != COMMA {
+			break
+		}
+		p.advance()
+	}
+	p.consume(RIGHTPARENT)
+	return call
+}
+
+/*
+<subscript> -> <exprlist>
+<subscript> -> [<test>] ":" [<test>] [":" [<test>]]
+*/
+func (p *Parser) subscript(x Expr) Expr {
+	s := &Subscript{Token: p.consume(LEFTBRACKET), X: x}
+	var lower Expr
+	if p.token.Category != COLON {
+		lower = p.valuelist()
+	}
+	if _, ok := lower.(*TupleLit); ok {
+		s.Index = lower
+		p.consume(RIGHTBRACKET)
+		return s
+	}
+	if p.token.Category != COLON {
+		s.Index = lower
+		p.consume(RIGHTBRACKET)
+		return s
+	}
+	slice := &Slice{Token: p.consume(COLON), Lower: lower}
+	if p.token.Category != COLON && p.token.Category != RIGHTBRACKET {
+		slice.Upper = p.test()
+	}
+	if p.token.Category == COLON {
+		p.advance()
+		if p.token.Category != RIGHTBRACKET {
+			slice.Step = p.test()
+		}
+	}
+	s.Index = slice
+	p.consume(RIGHTBRACKET)
+	return s
+}
+
+/*
+<atom> -> UNSIGNEDINT
+<atom> -> UNSIGNEDFLOAT
+<atom> -> NAME
+<atom> -> "(" [<exprlist>] ")"
+<atom> -> STRING
+<atom> -> TRUE
+<atom> -> FALSE
+<atom> -> NONE
+<atom> -> "[" [<starexpr> ("," <starexpr>)* [","]] "]"
+<atom> -> "{" [<test> ":" <test> ("," <test> ":" <test>)* [","]] "}"
+<atom> -> "{" <starexpr> ("," <starexpr>)* [","] "}"
+*/
+func (p *Parser) atom() Expr {
+	tok := p.token
+	switch tok.Category {
+	case UNSIGNEDINT:
+		lit := &IntLit{Token: tok}
+		i, err := strconv.Atoi(tok.Lexeme)
+		if err != nil {
+			b, ok := new(big.Int).SetString(tok.Lexeme, 10)
+			if !ok {
+				p.errorf("invalid integer %s", tok.Lexeme)
+			}
+			lit.Big = b
+		}
+		lit.Value = i
+		p.advance()
+		return lit
+	case UNSIGNEDFLOAT:
+		f, err := strconv.ParseFloat(tok.Lexeme, 64)
+		if err != nil {
+			p.errorf("invalid float %s", tok.Lexeme)
+		}
+		p.advance()
+		return &FloatLit{Token: tok, Value: f}
+	case NAME:
+		p.advance()
+		return &Name{Token: tok, Name: tok.Lexeme}
+	case LEFTPARENT:
+		p.advance()
+		if p.token.Category == RIGHTPARENT {
+			p.advance()
+			return &TupleLit{Token: tok}
+		}
+		start := p.token
+		x := p.exprlist()
+		if _, ok := x.(*Starred); ok {
+			p.fail(object.SyntaxError, start, "cannot use starred expression here")
+		}
+		p.consume(RIGHTPARENT)
+		return x
+	case STRING:
+		p.advance()
+		return &StrLit{Token: tok, Value: tok.Lexeme}
+	case TRUE, FALSE:
+		p.advance()
+		return &BoolLit{Token: tok, Value: tok.Category == TRUE}
+	case NONE:
+		p.advance()
+		return &NoneLit{Token: tok}
+	case LEFTBRACKET:
+		p.advance()
+		return &ListLit{Token: tok, Elts: p.displayitems(nil, RIGHTBRACKET)}
+	case LEFTBRACE:
+		p.advance()
+		if p.token.Category == RIGHTBRACE {
+			p.advance()
+			return &DictLit{Token: tok}
+		}
+		// the first item tell a set from a dict
+		first := p.starexpr()
+		if _, ok := first.(*Starred); ok || p.token.Category != COLON {
+			return &SetLit{Token: tok, Elts: p.displayitems(first, RIGHTBRACE)}
+		}
+		dict := &DictLit{Token: tok}
+		for {
+			dict.Keys = append(dict.Keys, first)
+			p.consume(COLON)
+			dict.Values = append(dict.Values, p.test())
+			if p.token.Category != COMMA {
+				break
+			}
+			p.advance()
+			if p.token.Category == RIGHTBRACE {
+				break
+			}
+			first = p.test()
+		}
+		p.consume(RIGHTBRACE)
+		return dict
+	}
+	p.errorf("expecting factor, got %s", p.describeToken())
+	return nil
+}
+
+// the items of a list or set display up to the closing bracket, first is
+// the first item when it is already parsed
+func (p *Parser) displayitems(first Expr, closing int) []Expr {
+	var items []Expr
+	if first != nil {
+		items = append(items, first)
+		if p.token.Category == COMMA {
+			p.advance()
+		} else {
+			p.consume(closing)
+			return items
+		}
+	}
+	for p.token.Category != closing {
+		items = append(items, p.starexpr())
+		if p.token.Category != COMMA {
+			break
+		}
+		p.advance()
+	}
+	p.consume(closing)
+	return items
+}
@@ -0,0 +1,418 @@
+// Symbol tables that find where every name of a module is bound, shared by
+// the compiler and the tree walking evaluator
+package symtable
+
+import (
+	"test1/object"
+	"test1/parser"
+	"test1/tokenizer"
+)
+
+// names of the module or of one function, found by walking the AST before
+// compiling so every name can be resolved to local, cell, free or global
+type symtable struct {
+	name        string
+	isModule    bool
+	parent      *symtable
+	children    []*symtable // nested functions in source order
+	byDef       map[parser.Node]*symtable
+	params      []string
+	locals      []string // assigned names, in order of first assignment
+	isLocal     map[string]bool
+	globals     map[string]bool // names declared by global
+	nonlocals   map[string]bool // names declared by nonlocal
+	declared    []string        // nonlocal names in order of declaration
+	nonlocalPos map[string]tokenizer.Token
+	used        []string // names read, in order of first use
+	isUsed      map[string]bool
+	cellvars    []string // locals captured by nested functions
+	isCell      map[string]bool
+	freevars    []string // names captured from enclosing functions
+	isFree      map[string]bool
+}
+
+func newSymtable(name string, parent *symtable) *symtable {
+	return &symtable{name: name, isModule: parent == nil, parent: parent,
+		byDef: map[parser.Node]*symtable{}, isLocal: map[string]bool{},
+		globals: map[string]bool{}, nonlocals: map[string]bool{}, nonlocalPos: map[string]tokenizer.Token{}, isUsed: map[string]bool{},
+		isCell: map[string]bool{}, isFree: map[string]bool{}}
+}
+
+// build the symtable tree of a module and resolve the free variables
+func buildSymtable(m *parser.Module) (*symtable, error) {
+	st := newSymtable("<module>", nil)
+	if err := st.visitBlock(m.Body); err != nil {
+		return nil, err
+	}
+	if err := st.resolve(); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+func (st *symtable) addLocal(name string) {
+	if !st.isLocal[name] && !st.globals[name] && !st.nonlocals[name] {
+		st.isLocal[name] = true
+		st.locals = append(st.locals, name)
+	}
+}
+
+func (st *symtable) addUse(name string) {
+	if !st.isUsed[name] {
+		st.isUsed[name] = true
+		st.used = append(st.used, name)
+	}
+}
+
+func (st *symtable) visitBlock(body []parser.Stmt) error {
+	for _, s := range body {
+		if err := st.visitStmt(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (st *symtable) visitStmt(stmt parser.Stmt) error {
+	switch s := stmt.(type) {
+	case *parser.Assign:
+		st.visitExpr(s.Value)
+		for _, target := range s.Targets {
+			st.visitTarget(target)
+		}
+	case *parser.Delete:
+		for _, target := range s.Targets {
+			st.visitTarget(target)
+		}
+	case *parser.ExprStmt:
+		st.visitExpr(s.X)
+	case *parser.Import:
+		for _, alias := range s.Names {
+			st.addLocal(alias.AsName)
+		}
+	case *parser.Return:
+		if st.isModule {
+			return syntaxError(s.Token, "'return' outside function")
+		}
+		if s.Value != nil {
+			st.visitExpr(s.Value)
+		}
+	case *parser.Global:
+		for _, name := range s.Names {
+			if err := st.checkDeclaration(s.Token, name, "global"); err != nil {
+				return err
+			}
+			if st.isLocal[name] || st.nonlocals[name] {
+				return syntaxError(s.Token, "name '%s' is assigned to before global declaration", name)
+			}
+			if !st.isModule {
+				st.globals[name] = true
+			}
+		}
+	case *parser.Nonlocal:
+		if st.isModule {
+			return syntaxError(s.Token, "nonlocal declaration not allowed at module level")
+		}
+		for _, name := range s.Names {
+			if err := st.checkDeclaration(s.Token, name, "nonlocal"); err != nil {
+				return err
+			}
+			if st.isLocal[name] || st.globals[name] {
+				return syntaxError(s.Token, "name '%s' is assigned to before nonlocal declaration", name)
+			}
+			if !st.nonlocals[name] {
+				st.nonlocals[name] = true
+				st.nonlocalPos[name] = s.Token
+				st.declared = append(st.declared, name)
+			}
+		}
+	case *parser.If:
+		st.visitExpr(s.Cond)
+		if err := st.visitBlock(s.Body); err != nil {
+			return err
+		}
+		return st.visitBlock(s.Else)
+	case *parser.While:
+		st.visitExpr(s.Cond)
+		if err := st.visitBlock(s.Body); err != nil {
+			return err
+		}
+		return st.visitBlock(s.Else)
+	case *parser.For:
+		st.visitTarget(s.Target)
+		st.visitExpr(s.Iter)
+		if err := st.visitBlock(s.Body); err != nil {
+			return err
+		}
+		return st.visitBlock(s.Else)
+	case *parser.FuncDef:
+		child := st.function(s, s.Name, s.Params)
+		st.addLocal(s.Name)
+		return child.visitBlock(s.Body)
+	case *parser.Try:
+		if err := st.visitBlock(s.Body); err != nil {
+			return err
+		}
+		for _, h := range s.Handlers {
+			if h.Type != nil {
+				st.visitExpr(h.Type)
+			}
+			if h.Name != "" {
+				st.addLocal(h.Name)
+			}
+			if err := st.visitBlock(h.Body); err != nil {
+				return err
+			}
+		}
+		if err := st.visitBlock(s.Else); err != nil {
+			return err
+		}
+		return st.visitBlock(s.Finally)
+	case *parser.Raise:
+		if s.Exc != nil {
+			st.visitExpr(s.Exc)
+		}
+		if s.Cause != nil {
+			st.visitExpr(s.Cause)
+		}
+	}
+	return nil
+}
+
+// a global or nonlocal declaration of a parameter or of a name already read
+func (st *symtable) checkDeclaration(at tokenizer.Token, name, kind string) error {
+	for _, param := range st.params {
+		if param == name {
+			return syntaxError(at, "name '%s' is parameter and %s", name, kind)
+		}
+	}
+	if st.isUsed[name] && !st.globals[name] && !st.nonlocals[name] {
+		return syntaxError(at, "name '%s' is used prior to %s declaration", name, kind)
+	}
+	return nil
+}
+
+// a name target is local, the parts of other targets are only used
+func (st *symtable) visitTarget(target parser.Expr) {
+	switch t := target.(type) {
+	case *parser.Name:
+		st.addLocal(t.Name)
+	case *parser.TupleLit:
+		for _, elt := range t.Elts {
+			st.visitTarget(elt)
+		}
+	case *parser.ListLit:
+		for _, elt := range t.Elts {
+			st.visitTarget(elt)
+		}
+	case *parser.Starred:
+		st.visitTarget(t.X)
+	default:
+		st.visitExpr(target)
+	}
+}
+
+func (st *symtable) visitExpr(expr parser.Expr) {
+	switch x := expr.(type) {
+	case *parser.Name:
+		st.addUse(x.Name)
+	case *parser.UnaryOp:
+		st.visitExpr(x.X)
+	case *parser.BinaryOp:
+		st.visitExpr(x.Left)
+		st.visitExpr(x.Right)
+	case *parser.BoolOp:
+		st.visitExpr(x.Left)
+		st.visitExpr(x.Right)
+	case *parser.IfExp:
+		st.visitExpr(x.Body)
+		st.visitExpr(x.Cond)
+		st.visitExpr(x.Else)
+	case *parser.Compare:
+		st.visitExpr(x.Left)
+		for _, right := range x.Comparators {
+			st.visitExpr(right)
+		}
+	case *parser.Call:
+		st.visitExpr(x.Func)
+		for _, arg := range x.Args {
+			st.visitExpr(arg)
+		}
+		for _, kw := range x.Keywords {
+			st.visitExpr(kw.Value)
+		}
+	case *parser.ListLit:
+		for _, elt := range x.Elts {
+			st.visitExpr(elt)
+		}
+	case *parser.TupleLit:
+		for _, elt := range x.Elts {
+			st.visitExpr(elt)
+		}
+	case *parser.SetLit:
+		for _, elt := range x.Elts {
+			st.visitExpr(elt)
+		}
+	case *parser.Starred:
+		st.visitExpr(x.X)
+	case *parser.DictLit:
+		for i := range x.Keys {
+			st.visitExpr(x.Keys[i])
+			st.visitExpr(x.Values[i])
+		}
+	case *parser.Subscript:
+		st.visitExpr(x.X)
+		st.visitExpr(x.Index)
+	case *parser.Slice:
+		for _, bound := range []parser.Expr{x.Lower, x.Upper, x.Step} {
+			if bound != nil {
+				st.visitExpr(bound)
+			}
+		}
+	case *parser.Attribute:
+		st.visitExpr(x.X)
+	case *parser.Lambda:
+		st.function(x, "<lambda>", x.Params).visitExpr(x.Body)
+	}
+}
+
+// the symtable of the function of a def or a lambda, its defaults are
+// evaluated in st
+func (st *symtable) function(node parser.Node, name string, params *parser.Params) *symtable {
+	for _, x := range params.Defaults {
+		st.visitExpr(x)
+	}
+	for _, x := range params.KwDefaults {
+		if x != nil {
+			st.visitExpr(x)
+		}
+	}
+	child := newSymtable(name, st)
+	for _, param := range params.Locals() {
+		child.params = append(child.params, param)
+		child.addLocal(param)
+	}
+	st.children = append(st.children, child)
+	st.byDef[node] = child
+	return child
+}
+
+// find where the names used in st and its children are bound, a name bound
+// in an enclosing function become a cell there and a free variable in every
+// function between
+func (st *symtable) resolve() error {
+	if !st.isModule {
+		for _, name := range st.used {
+			if !st.isLocal[name] && !st.globals[name] {
+				st.capture(name)
+			}
+		}
+		for _, name := range st.declared {
+			if !st.capture(name) {
+				return syntaxError(st.nonlocalPos[name], "no binding for nonlocal '%s' found", name)
+			}
+		}
+	}
+	for _, child := range st.children {
+		if err := child.resolve(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// look for name in the enclosing functions, return false if it is global
+func (st *symtable) capture(name string) bool {
+	var path []*symtable
+	for enc := st.parent; enc != nil && !enc.isModule; enc = enc.parent {
+		if enc.globals[name] {
+			return false
+		}
+		if enc.isLocal[name] {
+			if !enc.isCell[name] {
+				enc.isCell[name] = true
+				enc.cellvars = append(enc.cellvars, name)
+			}
+			for _, s := range append(path, st) {
+				s.addFree(name)
+			}
+			return true
+		}
+		if enc.isFree[name] {
+			for _, s := range append(path, st) {
+				s.addFree(name)
+			}
+			return true
+		}
+		path = append(path, enc)
+	}
+	return false
+}
+
+func (st *symtable) addFree(name string) {
+	if !st.isFree[name] {
+		st.isFree[name] = true
+		st.freevars = append(st.freevars, name)
+	}
+}
+
+// names of the module or of a function resolved before running it
+type Symbols struct {
+	st *symtable
+}
+
+// resolve the names of a module, every name used by it and its functions
+// get a Scope
+func Resolve(m *parser.Module) (*Symbols, error) {
+	st, err := buildSymtable(m)
+	if err != nil {
+		return nil, err
+	}
+	return &Symbols{st}, nil
+}
+
+// how a name is loaded and stored
+type Scope int
+
+const (
+	ScopeName   Scope = iota // module level name
+	ScopeLocal               // local of the function
+	ScopeCell                // local captured by nested functions, or free variable
+	ScopeGlobal              // global name from inside a function
+)
+
+func (s *Symbols) Scope(name string) Scope {
+	st := s.st
+	if st.isModule {
+		return ScopeName
+	}
+	if st.isCell[name] || st.isFree[name] {
+		return ScopeCell
+	}
+	if st.isLocal[name] {
+		return ScopeLocal
+	}
+	return ScopeGlobal
+}
+
+// symbols of the function made by a def or a lambda of this scope
+func (s *Symbols) Function(node parser.Node) *Symbols { return &Symbols{s.st.byDef[node]} }
+
+// parameters of the function, in order
+func (s *Symbols) Params() []string { return s.st.params }
+
+// assigned names, parameters included, in order of first assignment
+func (s *Symbols) Locals() []string { return s.st.locals }
+
+// locals captured by nested functions and names captured from the
+// enclosing functions
+func (s *Symbols) Cellvars() []string { return s.st.cellvars }
+func (s *Symbols) Freevars() []string { return s.st.freevars }
+
+func (s *Symbols) IsCell(name string) bool { return s.st.isCell[name] }
+
+func syntaxError(at tokenizer.Token, format string, args ...interface{}) *object.Exception {
+	exc := object.Errorf(object.SyntaxError, format, args...)
+	exc.Row, exc.Column = at.Row, at.Column
+	return exc
+}
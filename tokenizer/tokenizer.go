@@ -3,19 +3,11 @@ package tokenizer
 
 import (
     "fmt"
-    "os"
+    "io"
     "unicode"
 )
 
 // each token keep track of 4 pieces of information: row, col, category, and lexeme
-type token struct {
-    row int
-    column int
-    category int   // see token categories below
-    lexeme string  // the literal string of the token
-}
-
-// exported token struct
 type Token struct {
     Row int
     Column int
@@ -26,7 +18,6 @@ type Token struct {
 // constants to represent token categories
 const (
     EOF = iota // end of file
-    PRINT  // 'print' keyword
     UNSIGNEDINT // integer
     NAME // identifier that is not a keyword
     ASSIGNOP // '='
@@ -60,18 +51,58 @@ const (
     DEF     // start of t3
     RETURN
     GLOBAL
-    INPUT
-    INT
+    NONLOCAL
+    TRY
+    EXCEPT
+    FINALLY
+    RAISE
+    FROM
+    AS
+    LEFTBRACKET // '['
+    RIGHTBRACKET // ']'
+    DOT // '.'
+    DEL
+    LEFTBRACE // '{'
+    RIGHTBRACE // '}'
+    IN
+    BITOR // '|'
+    BITAND // '&'
+    BITXOR // '^'
+    FOR     // start of t4
+    BREAK
+    CONTINUE
+    ELIF
+    AND
+    OR
+    NOT
+    IS
+    NOTIN // 'not in', made by the parser from NOT IN
+    ISNOT // 'is not', made by the parser from IS NOT
+    FLOORDIV // '//'
+    MOD // '%'
+    POWER // '**'
+    INVERT // '~'
+    LSHIFT // '<<'
+    RSHIFT // '>>'
+    IMPORT // 'import' keyword
+    LAMBDA // 'lambda' keyword
 )
 
 // keywords and their category
 var keyWords = map[string]int {
-    "print" : PRINT, "None" : NONE, "True" : TRUE,
+    "None" : NONE, "True" : TRUE,
     "False" : FALSE, "pass" : PASS, "if" : IF,
     "else" : ELSE, "while" : WHILE,
     //start of t3 
     "def" : DEF, "return" : RETURN, "global" : GLOBAL, 
-    "input" : INPUT, "int" : INT,
+    "nonlocal" : NONLOCAL,
+    "try" : TRY, "except" : EXCEPT, "finally" : FINALLY,
+    "raise" : RAISE, "from" : FROM, "as" : AS, "del" : DEL,
+    "in" : IN,
+    //start of t4
+    "for" : FOR, "break" : BREAK, "continue" : CONTINUE,
+    "elif" : ELIF, "and" : AND, "or" : OR, "not" : NOT, "is" : IS,
+    "import" : IMPORT, "lambda" : LAMBDA,
 }
 
 // one-character tokens and their category
@@ -80,45 +111,163 @@ var smallTokens = map[string]int {
     "-" : MINUS, "*" : TIMES, "\n" : NEWLINE, "" : EOF, "==" : EQUAL,
     "<" : LESSTHAN, "<=" : LESSEQUAL, ">" : GREATERTHAN, ">=" : GREATEREQUAL,
     "!" : ERROR, "!=" : NOTEQUAL, "," : COMMA, ":" : COLON, "/" : DIV,
+    "[" : LEFTBRACKET, "]" : RIGHTBRACKET, "." : DOT, "{" : LEFTBRACE, "}" : RIGHTBRACE,
+    "|" : BITOR, "&" : BITAND, "^" : BITXOR, "//" : FLOORDIV, "%" : MOD, "**" : POWER,
+    "~" : INVERT, "<<" : LSHIFT, ">>" : RSHIFT,
+}
+
+// error found while scanning, with the position of the bad character
+type ScanError struct {
+    Row int
+    Column int
+    Msg string
+    Indentation bool // true when the indentation is wrong
+    Unclosed bool    // true when the source end inside brackets, more lines may close them
+}
+
+func (e *ScanError) Error() string {
+    return fmt.Sprintf("%s on line %d", e.Msg, e.Row)
+}
+
+// return a readable name for a token category, used in error messages
+func Describe(category int) string {
+    switch category {
+    case EOF:
+        return "end of file"
+    case UNSIGNEDINT:
+        return "integer"
+    case UNSIGNEDFLOAT:
+        return "float"
+    case NAME:
+        return "name"
+    case STRING:
+        return "string"
+    case NEWLINE:
+        return "newline"
+    case INDENT:
+        return "indent"
+    case DEDENT:
+        return "dedent"
+    case NOTIN:
+        return "'not in'"
+    case ISNOT:
+        return "'is not'"
+    }
+    for lexeme, c := range keyWords {
+        if c == category {
+            return "'" + lexeme + "'"
+        }
+    }
+    for lexeme, c := range smallTokens {
+        if c == category {
+            return "'" + lexeme + "'"
+        }
+    }
+    return "unknown token"
+}
+
+// Lexer turn one source string into tokens, all scanning state lives here so
+// several lexers can run at the same time
+type Lexer struct {
+    source string     // source code being tokenized
+    sourceIndex int   // keep track of current character's index in source string
+    prevChar byte     // save previous character
+    currChar byte     // character waiting to be scanned
+    line int          // line number of current token
+    column int        // column number of current token
+    isBlankLine bool  // check if current line is a bankline
+    isInString bool
+    indentStack []int
+    lastCategory int  // category of the last scanned token, -1 before the first one
+    brackets []Token  // open brackets, newlines inside them do not end the statement
+    pending []Token   // scanned tokens not returned by Next yet
+    err error         // first scanning error, returned forever after
+}
+
+// create a lexer for source, a missing newline at the end is added
+func NewLexer(source string) *Lexer {
+    // Python use newline character '\n' to terminate statement, in some editor the
+    // last line might not terminate if writer not hit enter key, so below check for
+    // newline character and add it to source if it not there
+    if len(source) > 0 && source[len(source)-1] != '\n' {
+        source += "\n"
+    }
+    return &Lexer{source: source, prevChar: '\n', currChar: ' ', isBlankLine: true,
+        indentStack: []int{1}, lastCategory: -1}
+}
+
+// create a lexer that read all of r
+func NewReaderLexer(r io.Reader) (*Lexer, error) {
+    data, err := io.ReadAll(r)
+    if err != nil {
+        return nil, err
+    }
+    return NewLexer(string(data)), nil
+}
+
+// tokenize the whole source, the last token is EOF
+func Tokenize(source string) ([]Token, error) {
+    l := NewLexer(source)
+    var tokens []Token
+    for {
+        t, err := l.Next()
+        if err != nil {
+            return nil, err
+        }
+        tokens = append(tokens, t)
+        if t.Category == EOF {
+            return tokens, nil
+        }
+    }
 }
 
-// exported tokenlist
-var TokenList []Token
+// return the next token and move past it, after EOF keep returning EOF
+func (l *Lexer) Next() (Token, error) {
+    t, err := l.Peek()
+    if err == nil && t.Category != EOF {
+        l.pending = l.pending[1:]
+    }
+    return t, err
+}
+
+// return the next token without moving past it
+func (l *Lexer) Peek() (Token, error) {
+    if len(l.pending) == 0 && l.err == nil {
+        l.err = l.scan()
+    }
+    if len(l.pending) > 0 {
+        return l.pending[0], nil
+    }
+    return Token{}, l.err
+}
 
-// package level var,
-var tokenlist []token // store tokens
-var source string     // string of source file
-var sourceIndex int   // keep track of current character's index in source string
-var prevChar byte = '\n'     // save previous character
-var line int          // line number of current token
-var column int        // column number of current token
-var isBlankLine bool = true    // check if current line is a bankline 
-var isInString bool
-var parenLevel int    //
+func (l *Lexer) errorf(format string, args ...interface{}) error {
+    return &ScanError{Row: l.line, Column: l.column, Msg: fmt.Sprintf(format, args...)}
+}
 
 // return the current character pointed by sourceIndex and adjust row and column.
-func getChar() byte {
-    if prevChar == '\n' {
-        line += 1
-        column = 0
-        isBlankLine = true
+func (l *Lexer) getChar() byte {
+    if l.prevChar == '\n' {
+        l.line += 1
+        l.column = 0
+        l.isBlankLine = true
     }
 
-    // if at the end of source file, change isEOF flag
-    if sourceIndex >= len(source) {
-        column = 1
+    // if at the end of source file, return 0
+    if l.sourceIndex >= len(l.source) {
+        l.column = 1
         return 0
     }
 
-    c := source[sourceIndex]
-    sourceIndex += 1
-    column += 1
+    c := l.source[l.sourceIndex]
+    l.sourceIndex += 1
+    l.column += 1
 
     // check if # is in string or start of comment
-    if c == '#' && !isInString {
-        for {
-            c = source[sourceIndex]
-            sourceIndex += 1
+    if c == '#' && !l.isInString {
+        for l.sourceIndex < len(l.source) {
+            c = l.source[l.sourceIndex]
+            l.sourceIndex += 1
             if c == '\n' {
                 break
             }
@@ -126,189 +275,173 @@ func getChar() byte {
     }
 
     if !unicode.IsSpace(rune(c)) {
-        isBlankLine = false
+        l.isBlankLine = false
     }
-    prevChar = c
+    l.prevChar = c
 
     // if at the end of blankline, return ' ' instead of '\n'
-    if c == '\n' && isBlankLine {
+    if c == '\n' && l.isBlankLine {
         return ' '
     } else {
         return c
     }
 }
 
-func tokenizer() {
-    var currChar byte = ' '
-    indentStack := []int{1}
-    for {
-        // skip white space but not newline
-        for currChar != '\n' && unicode.IsSpace(rune(currChar)) {
-            currChar = getChar()
-        }
+// scan one token and add it to pending, with the INDENT or DEDENT tokens
+// that come before it
+func (l *Lexer) scan() error {
+    // skip white space but not newline
+    for l.currChar != '\n' && unicode.IsSpace(rune(l.currChar)) {
+        l.currChar = l.getChar()
+    }
 
-        var currToken = token{row: line, column: column, category: -1, lexeme: ""} 
-        if unicode.IsDigit(rune(currChar)) || currChar == '.'{
-            currToken.category = UNSIGNEDINT
-            if currChar == '.' {
-                currToken.category = UNSIGNEDFLOAT
-            }
-            for {
-                currToken.lexeme += string(currChar)
-                currChar = getChar()
-                if currToken.category == UNSIGNEDINT && currChar == '.' {
-                    currToken.category = UNSIGNEDFLOAT
-                } else if !unicode.IsDigit(rune(currChar)) {
-                    break
-                }
-            }
-        } else if unicode.IsLetter(rune(currChar)) || currChar == '_' {
-            for {
-                currToken.lexeme += string(currChar)
-                currChar = getChar()
-                if !(unicode.IsLetter(rune(currChar)) || unicode.IsDigit(rune(currChar)) || currChar == '_') {
-                    break
-                }
+    var currToken = Token{Row: l.line, Column: l.column, Category: -1, Lexeme: ""}
+    currChar := l.currChar
+    if unicode.IsDigit(rune(currChar)) || currChar == '.' && l.sourceIndex < len(l.source) && unicode.IsDigit(rune(l.source[l.sourceIndex])) {
+        currToken.Category = UNSIGNEDINT
+        if currChar == '.' {
+            currToken.Category = UNSIGNEDFLOAT
+        }
+        for {
+            currToken.Lexeme += string(currChar)
+            currChar = l.getChar()
+            if currToken.Category == UNSIGNEDINT && currChar == '.' {
+                currToken.Category = UNSIGNEDFLOAT
+            } else if !unicode.IsDigit(rune(currChar)) {
+                break
             }
+        }
 
-            // check the token's lexeme to see if it is keyword or user var name 
-            if v, ok := keyWords[currToken.lexeme]; ok {
-                currToken.category = v
-            } else {
-                currToken.category = NAME
+        // exponent with an optional sign, ex: 1e-05
+        next := l.sourceIndex
+        if next < len(l.source) && (l.source[next] == '+' || l.source[next] == '-') {
+            next++
+        }
+        if (currChar == 'e' || currChar == 'E') && next < len(l.source) && unicode.IsDigit(rune(l.source[next])) {
+            currToken.Category = UNSIGNEDFLOAT
+            currToken.Lexeme += string(currChar)
+            currChar = l.getChar()
+            if currChar == '+' || currChar == '-' {
+                currToken.Lexeme += string(currChar)
+                currChar = l.getChar()
             }
-            
-        } else if _, ok := smallTokens[string(currChar)]; ok {
-            saveChar := currChar
-            currChar = getChar()
-            twoChar := string(saveChar) + string(currChar)
-            if v, ok := smallTokens[twoChar]; ok {
-                currToken.category = v
-                currToken.lexeme = string(twoChar)
-                currChar = getChar()
-            } else {
-                currToken.category = smallTokens[string(saveChar)]
-                currToken.lexeme = string(saveChar)
+            for unicode.IsDigit(rune(currChar)) {
+                currToken.Lexeme += string(currChar)
+                currChar = l.getChar()
             }
-        } else if currChar == 39 {
-            // current char is single quote ', indicate start of string data
-            isInString = true
-            for {
-                currChar = getChar()
-                if currChar == 0 || currChar == '\n'{
-                    fmt.Println("unterminated string on", line)
-                    os.Exit(1)
-                }
-                if currChar == 39 { // currChar == '
-                    currChar = getChar() // advance pass last single quote
-                    currToken.category = STRING
-                    isInString = false
-                    break
-                }
-                if currChar == 92 { // currChar == \
-                    currChar = getChar()
-                    if currChar == 'n' {
-                        currToken.lexeme += "\n"
-                    } else if currChar == 't' {
-                        currToken.lexeme += "\t"
-                    } else if currChar == '\n' {
-                        currToken.lexeme += "n"
-                    } else {
-                        currToken.lexeme += string(currChar)
-                    }
-                } else {
-                    currToken.lexeme += string(currChar)
-                }
+        }
+    } else if unicode.IsLetter(rune(currChar)) || currChar == '_' {
+        for {
+            currToken.Lexeme += string(currChar)
+            currChar = l.getChar()
+            if !(unicode.IsLetter(rune(currChar)) || unicode.IsDigit(rune(currChar)) || currChar == '_') {
+                break
             }
+        }
 
-        } else if currChar == 0 {
-            currToken.category = EOF
-            currToken.lexeme = ""
+        // check the token's lexeme to see if it is keyword or user var name
+        if v, ok := keyWords[currToken.Lexeme]; ok {
+            currToken.Category = v
         } else {
-            currToken.category = ERROR
-            currToken.lexeme = string(currChar)
-            fmt.Println("error occur with token " + string(currChar) + " on line", line)
-            os.Exit(1)
+            currToken.Category = NAME
         }
 
-        if len(tokenlist) == 0 || tokenlist[len(tokenlist)-1].category == NEWLINE {
-            if indentStack[len(indentStack)-1] < currToken.column{
-                indentStack = append(indentStack, currToken.column)
-                var indentToken = token{row: currToken.row, column: currToken.column, category: INDENT, lexeme : "{"}
-                tokenlist = append(tokenlist, indentToken) 
-            } else if indentStack[len(indentStack)-1] > currToken.column {
-                for {
-                    var dedentToken = token{row: currToken.row, column: currToken.column, category : DEDENT, lexeme : "}"}
-                    tokenlist = append(tokenlist, dedentToken)
-                    indentStack = indentStack[:len(indentStack)-1]
-                    if indentStack[len(indentStack)-1] == currToken.column {
-                        break
-                    } else if indentStack[len(indentStack)-1] < currToken.column {
-                        fmt.Println("indetation err on line", line)
-                        os.Exit(1)
-                    }
+    } else if _, ok := smallTokens[string(currChar)]; ok {
+        saveChar := currChar
+        currChar = l.getChar()
+        twoChar := string(saveChar) + string(currChar)
+        if v, ok := smallTokens[twoChar]; ok {
+            currToken.Category = v
+            currToken.Lexeme = string(twoChar)
+            currChar = l.getChar()
+        } else {
+            currToken.Category = smallTokens[string(saveChar)]
+            currToken.Lexeme = string(saveChar)
+        }
+        if currToken.Category == ERROR {
+            return l.errorf("invalid syntax")
+        }
+    } else if currChar == 39 {
+        // current char is single quote ', indicate start of string data
+        l.isInString = true
+        for {
+            currChar = l.getChar()
+            if currChar == 0 || currChar == '\n' {
+                return l.errorf("unterminated string literal (detected at line %d)", l.line)
+            }
+            if currChar == 39 { // currChar == '
+                currChar = l.getChar() // advance pass last single quote
+                currToken.Category = STRING
+                l.isInString = false
+                break
+            }
+            if currChar == 92 { // currChar == \
+                currChar = l.getChar()
+                if currChar == 'n' {
+                    currToken.Lexeme += "\n"
+                } else if currChar == 't' {
+                    currToken.Lexeme += "\t"
+                } else if currChar == '\n' {
+                    currToken.Lexeme += "n"
+                } else {
+                    currToken.Lexeme += string(currChar)
                 }
+            } else {
+                // keep the bytes as they are so utf-8 text stay valid
+                currToken.Lexeme += string([]byte{currChar})
             }
         }
 
-        tokenlist = append(tokenlist, currToken)
-        if currToken.category == EOF {
-            break
+    } else if currChar == 0 {
+        if len(l.brackets) > 0 {
+            open := l.brackets[len(l.brackets)-1]
+            return &ScanError{Row: open.Row, Column: open.Column,
+                Msg: fmt.Sprintf("'%s' was never closed", open.Lexeme), Unclosed: true}
         }
+        currToken.Category = EOF
+        currToken.Lexeme = ""
+    } else {
+        return l.errorf("invalid character '%s'", string(currChar))
     }
-}
+    l.currChar = currChar
 
-// a function to read in souce file from command line argument
-// and return the source string
-func readSourceFile() string {
-    // check to see if valid number of cmd line args
-    if len(os.Args) == 2 {
-        data, err := os.ReadFile(os.Args[1])
-        if err != nil {
-            fmt.Println("Can not read input file" + os.Args[1])
-            os.Exit(1)
+    // implicit line joining: inside brackets a newline is only a space
+    switch currToken.Category {
+    case LEFTPARENT, LEFTBRACKET, LEFTBRACE:
+        l.brackets = append(l.brackets, currToken)
+    case RIGHTPARENT, RIGHTBRACKET, RIGHTBRACE:
+        if len(l.brackets) > 0 {
+            l.brackets = l.brackets[:len(l.brackets)-1]
         }
-        // Python use newline character '\n' to terminate statement, in some editor the
-        // last line might not terminate if writer not hit enter key, so below check for
-        // newline character and add it to source if it not there
-        if data[len(data)-1] != '\n' {
-            data = append(data, '\n')
+    case NEWLINE:
+        if len(l.brackets) > 0 {
+            return l.scan()
         }
-        return string(data)
-    } else {
-        fmt.Println("invalid number of command line arguments")
-        fmt.Println("usage: ./tokenizer <infile>")
-        os.Exit(1)
-        return "" // never reach, added to pass compiler's complain of no return statement
-    } 
-}
-
-// exported function
-/* read cmd arg file and build tokenlist
-func start() {
-    // read source from source python file
-    source = readSourceFile()
-    tokenizer()
-    
-    for _, token := range tokenlist {
-        fmt.Printf("%d %d %s\n", token.row, token.column, string(token.lexeme))
     }
-    fmt.Println("length of tokenlist" , len(tokenlist))
-}
-*/
 
-// create copy of tokenlist and return the copy list
-func BuildTokenList() {
-    source = readSourceFile()
-    tokenizer()
-    for _, token := range tokenlist {
-        TokenList = append(TokenList, Token{Row: token.row, Column: token.column, Category: token.category, Lexeme: token.lexeme})
+    if l.lastCategory == -1 || l.lastCategory == NEWLINE {
+        indentStack := l.indentStack
+        if indentStack[len(indentStack)-1] < currToken.Column {
+            indentStack = append(indentStack, currToken.Column)
+            var indentToken = Token{Row: currToken.Row, Column: currToken.Column, Category: INDENT, Lexeme: "{"}
+            l.pending = append(l.pending, indentToken)
+        } else if indentStack[len(indentStack)-1] > currToken.Column {
+            for {
+                var dedentToken = Token{Row: currToken.Row, Column: currToken.Column, Category: DEDENT, Lexeme: "}"}
+                l.pending = append(l.pending, dedentToken)
+                indentStack = indentStack[:len(indentStack)-1]
+                if indentStack[len(indentStack)-1] == currToken.Column {
+                    break
+                } else if indentStack[len(indentStack)-1] < currToken.Column {
+                    return &ScanError{Row: currToken.Row, Column: currToken.Column,
+                        Msg: "unindent does not match any outer indentation level", Indentation: true}
+                }
+            }
+        }
+        l.indentStack = indentStack
     }
-}
-
-
-
-
-
-
 
+    l.lastCategory = currToken.Category
+    l.pending = append(l.pending, currToken)
+    return nil
+}
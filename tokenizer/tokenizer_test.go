@@ -0,0 +1,153 @@
+package tokenizer
+
+import (
+    "errors"
+    "fmt"
+    "reflect"
+    "sync"
+    "testing"
+)
+
+// categories and lexemes of the tokens of source, up to EOF
+func scanAll(t *testing.T, source string) ([]int, []string) {
+    t.Helper()
+    tokens, err := Tokenize(source)
+    if err != nil {
+        t.Fatalf("Tokenize(%q): %v", source, err)
+    }
+    var categories []int
+    var lexemes []string
+    for _, token := range tokens {
+        categories = append(categories, token.Category)
+        lexemes = append(lexemes, token.Lexeme)
+    }
+    return categories, lexemes
+}
+
+func TestTokens(t *testing.T) {
+    tests := []struct {
+        source string
+        categories []int
+        lexemes []string
+    }{
+        {"x = 1\n", []int{NAME, ASSIGNOP, UNSIGNEDINT, NEWLINE, EOF}, []string{"x", "=", "1", "\n", ""}},
+        // the missing newline at the end is added
+        {"x", []int{NAME, NEWLINE, EOF}, []string{"x", "\n", ""}},
+        {"1e-05 .5 2. a.b\n", []int{UNSIGNEDFLOAT, UNSIGNEDFLOAT, UNSIGNEDFLOAT, NAME, DOT, NAME, NEWLINE, EOF},
+            []string{"1e-05", ".5", "2.", "a", ".", "b", "\n", ""}},
+        {"a <= b != c // d ** e\n", []int{NAME, LESSEQUAL, NAME, NOTEQUAL, NAME, FLOORDIV, NAME, POWER, NAME, NEWLINE, EOF},
+            []string{"a", "<=", "b", "!=", "c", "//", "d", "**", "e", "\n", ""}},
+        {"def f(): return None\n", []int{DEF, NAME, LEFTPARENT, RIGHTPARENT, COLON, RETURN, NONE, NEWLINE, EOF},
+            []string{"def", "f", "(", ")", ":", "return", "None", "\n", ""}},
+        {"s = 'a\\nb\\'c'\n", []int{NAME, ASSIGNOP, STRING, NEWLINE, EOF}, []string{"s", "=", "a\nb'c", "\n", ""}},
+        // comments and blank lines make no token
+        {"x # c\n\n# only\ny\n", []int{NAME, NEWLINE, NAME, NEWLINE, EOF}, []string{"x", "\n", "y", "\n", ""}},
+        // a newline inside brackets does not end the statement
+        {"f(1,\n  [2,\n3])\n", []int{NAME, LEFTPARENT, UNSIGNEDINT, COMMA, LEFTBRACKET, UNSIGNEDINT, COMMA,
+            UNSIGNEDINT, RIGHTBRACKET, RIGHTPARENT, NEWLINE, EOF},
+            []string{"f", "(", "1", ",", "[", "2", ",", "3", "]", ")", "\n", ""}},
+    }
+    for _, test := range tests {
+        categories, lexemes := scanAll(t, test.source)
+        if !reflect.DeepEqual(categories, test.categories) {
+            t.Errorf("Tokenize(%q) categories = %v, want %v", test.source, categories, test.categories)
+        }
+        if !reflect.DeepEqual(lexemes, test.lexemes) {
+            t.Errorf("Tokenize(%q) lexemes = %q, want %q", test.source, lexemes, test.lexemes)
+        }
+    }
+}
+
+func TestIndentation(t *testing.T) {
+    source := "if x:\n    if y:\n        z\nw\n"
+    tokens, err := Tokenize(source)
+    if err != nil {
+        t.Fatal(err)
+    }
+    want := []Token{
+        {1, 1, IF, "if"}, {1, 4, NAME, "x"}, {1, 5, COLON, ":"}, {1, 6, NEWLINE, "\n"},
+        {2, 5, INDENT, "{"}, {2, 5, IF, "if"}, {2, 8, NAME, "y"}, {2, 9, COLON, ":"}, {2, 10, NEWLINE, "\n"},
+        {3, 9, INDENT, "{"}, {3, 9, NAME, "z"}, {3, 10, NEWLINE, "\n"},
+        {4, 1, DEDENT, "}"}, {4, 1, DEDENT, "}"}, {4, 1, NAME, "w"}, {4, 2, NEWLINE, "\n"},
+        {5, 1, EOF, ""},
+    }
+    if !reflect.DeepEqual(tokens, want) {
+        t.Errorf("Tokenize(%q) =\n%v\nwant\n%v", source, tokens, want)
+    }
+}
+
+func TestScanError(t *testing.T) {
+    tests := []struct {
+        source string
+        want ScanError
+    }{
+        {"x = (1,\n", ScanError{Row: 1, Column: 5, Msg: "'(' was never closed", Unclosed: true}},
+        {"f(a,\n  [b\n", ScanError{Row: 2, Column: 3, Msg: "'[' was never closed", Unclosed: true}},
+        {"if x:\n    y\n  z\n", ScanError{Row: 3, Column: 3, Msg: "unindent does not match any outer indentation level", Indentation: true}},
+        {"x = 'abc\n", ScanError{Row: 1, Column: 9, Msg: "unterminated string literal (detected at line 1)"}},
+        {"x = $\n", ScanError{Row: 1, Column: 5, Msg: "invalid character '$'"}},
+    }
+    for _, test := range tests {
+        _, err := Tokenize(test.source)
+        var scanErr *ScanError
+        if !errors.As(err, &scanErr) || *scanErr != test.want {
+            t.Errorf("Tokenize(%q) error = %#v, want %#v", test.source, err, &test.want)
+        }
+    }
+}
+
+func TestNextAndPeek(t *testing.T) {
+    l := NewLexer("x = $\n")
+    if p, _ := l.Peek(); p.Lexeme != "x" {
+        t.Errorf("Peek = %v, want x", p)
+    }
+    if n, _ := l.Next(); n.Lexeme != "x" {
+        t.Errorf("Next = %v, want x", n)
+    }
+    l.Next()
+    // the first error is returned forever after
+    _, err1 := l.Next()
+    _, err2 := l.Peek()
+    if err1 == nil || err1 != err2 {
+        t.Errorf("errors = %v, %v, want the same error twice", err1, err2)
+    }
+
+    l = NewLexer("")
+    for i := 0; i < 2; i++ {
+        if n, err := l.Next(); err != nil || n.Category != EOF {
+            t.Errorf("Next on empty source = %v, %v, want EOF", n, err)
+        }
+    }
+}
+
+// each lexer keep its own state
+func TestConcurrentLexers(t *testing.T) {
+    var wg sync.WaitGroup
+    for i := 0; i < 8; i++ {
+        wg.Add(1)
+        go func(i int) {
+            defer wg.Done()
+            source := fmt.Sprintf("def f%d():\n    return (%d,\n        %d)\nf%d()\n", i, i, i, i)
+            categories, lexemes := scanAll(t, source)
+            want := []int{DEF, NAME, LEFTPARENT, RIGHTPARENT, COLON, NEWLINE, INDENT, RETURN, LEFTPARENT,
+                UNSIGNEDINT, COMMA, UNSIGNEDINT, RIGHTPARENT, NEWLINE, DEDENT, NAME, LEFTPARENT, RIGHTPARENT, NEWLINE, EOF}
+            if !reflect.DeepEqual(categories, want) {
+                t.Errorf("lexer %d categories = %v, want %v", i, categories, want)
+            }
+            if name := fmt.Sprintf("f%d", i); lexemes[1] != name || lexemes[15] != name {
+                t.Errorf("lexer %d lexemes = %q", i, lexemes)
+            }
+        }(i)
+    }
+    wg.Wait()
+}
+
+func TestDescribe(t *testing.T) {
+    for category, want := range map[int]string{
+        EOF: "end of file", NAME: "name", NOTIN: "'not in'", WHILE: "'while'", RIGHTBRACKET: "']'", LSHIFT: "'<<'",
+    } {
+        if got := Describe(category); got != want {
+            t.Errorf("Describe(%d) = %q, want %q", category, got, want)
+        }
+    }
+}
@@ -0,0 +1,642 @@
+// Stack based virtual machine that run the bytecode made by the compiler
+package vm
+
+import (
+	"fmt"
+	"io"
+	"test1/compiler"
+	"test1/object"
+	. "test1/tokenizer"
+)
+
+// variable shared between a function and the functions nested in it,
+// Value is nil until the variable is assigned
+type Cell struct {
+	Value object.Value
+}
+
+var CellType = &object.Type{Name: "cell"}
+
+func (c *Cell) Type() *object.Type { return CellType }
+func (c *Cell) Repr() string       { return "<cell>" }
+func (c *Cell) Truth() bool        { return true }
+
+// function object made by MAKE_FUNCTION
+type Function struct {
+	Code       *compiler.Code
+	Cells      []*Cell                 // cells of the free variables, in Code.Freevars order
+	Defaults   []object.Value          // defaults of the last positional parameters
+	KwDefaults map[string]object.Value // defaults of the keyword-only parameters
+	vm         *VM                     // vm running the function when a builtin call it
+}
+
+func (f *Function) Type() *object.Type { return object.FunctionType }
+func (f *Function) Repr() string       { return fmt.Sprintf("<function %s at %p>", f.Code.Name, f) }
+func (f *Function) Truth() bool        { return true }
+func (f *Function) FuncName() string   { return f.Code.Name }
+
+// call from a builtin, ex: the key function of min()
+func (f *Function) Call(args []object.Value, kwargs map[string]object.Value) (object.Value, error) {
+	args, kwnames := object.SortedKeywords(args, kwargs)
+	return f.vm.call(f, args, kwnames)
+}
+
+type VM struct {
+	globals  map[string]object.Value // module level names and their values
+	Builtins map[string]object.Value // names found when they are not global
+	depth    int                     // number of running function calls
+	handling []*object.Exception     // exceptions being handled by except clauses, innermost last
+	object.Streams
+
+	// when Done is closed the running code raise KeyboardInterrupt at the
+	// next loop iteration or function call, ex: the Done of a context
+	Done <-chan struct{}
+}
+
+// block pushed by SETUP_FINALLY
+type block struct {
+	handler int // where to jump when an exception is raised inside the block
+	sp      int // stack depth to restore before pushing the exception
+}
+
+// deepest frame nesting before RecursionError, the module is the first frame
+const maxDepth = 1000
+
+func New() *VM {
+	vm := &VM{globals: make(map[string]object.Value), Streams: object.StandardStreams()}
+	vm.Builtins = object.NewBuiltins(&vm.Streams)
+	return vm
+}
+
+// forget the calls a Go panic left running, the globals are kept
+func (vm *VM) Reset() {
+	vm.depth = 0
+	vm.handling = nil
+}
+
+// run the code of a module, an uncaught exception is returned as an
+// *object.Exception
+func (vm *VM) Run(code *compiler.Code) error {
+	_, err := vm.Eval(code)
+	return err
+}
+
+// run the code of a module and return the value it return, used with the
+// code of compiler.CompileExpr
+func (vm *VM) Eval(code *compiler.Code) (object.Value, error) {
+	return vm.run(code, nil, nil)
+}
+
+// error for the empty cell i, a captured local is unbound like the other
+// locals
+func unboundCell(code *compiler.Code, i int) *object.Exception {
+	if i < len(code.Cellvars) {
+		return object.Errorf(object.UnboundLocalError, "local variable '%s' referenced before assignment", code.Cellvars[i])
+	}
+	return object.Errorf(object.NameError, "free variable '%s' referenced before assignment in enclosing scope", code.CellName(i))
+}
+
+func (vm *VM) interrupted() bool {
+	select {
+	case <-vm.Done:
+		return true
+	default:
+		return false
+	}
+}
+
+// value of a module level name
+func (vm *VM) Global(name string) (object.Value, bool) {
+	v, ok := vm.globals[name]
+	return v, ok
+}
+
+func (vm *VM) SetGlobal(name string, v object.Value) {
+	vm.globals[name] = v
+}
+
+// add the position of the instruction at ip to the traceback of err, every
+// exception get one entry for the frame raising it
+func raise(err error, code *compiler.Code, ip int) *object.Exception {
+	exc := object.AsException(err)
+	pos := code.Pos[ip]
+	exc.AddTraceback(code.Name, pos.Row, pos.Column)
+	return exc
+}
+
+// exception of the innermost running except clause, nil outside of them
+func (vm *VM) handledException() *object.Exception {
+	if len(vm.handling) == 0 {
+		return nil
+	}
+	return vm.handling[len(vm.handling)-1]
+}
+
+// run one code object until it return, locals hold the arguments in their
+// first slots and freeCells the cells of the function's free variables
+func (vm *VM) run(code *compiler.Code, locals []object.Value, freeCells []*Cell) (object.Value, error) {
+	cells := make([]*Cell, 0, len(code.Cellvars)+len(freeCells))
+	for range code.Cellvars {
+		cells = append(cells, &Cell{})
+	}
+	cells = append(cells, freeCells...)
+
+	stack := make([]object.Value, code.StackSize)
+	sp := 0 // index of the first free stack slot
+	var blocks []block
+	instrs := code.Instrs
+	for ip := 0; ip < len(instrs); {
+		in := instrs[ip]
+		ip++
+		var exc *object.Exception // raised by the instruction
+		switch in.Op() {
+		case compiler.LOAD_CONST:
+			stack[sp] = code.Consts[in.Arg()]
+			sp++
+		case compiler.LOAD_NAME, compiler.LOAD_GLOBAL:
+			v, ok := vm.globals[code.Names[in.Arg()]]
+			if !ok {
+				v, ok = vm.Builtins[code.Names[in.Arg()]]
+			}
+			if !ok {
+				exc = raise(object.Errorf(object.NameError, "name '%s' is not defined", code.Names[in.Arg()]), code, ip-1)
+				break
+			}
+			stack[sp] = v
+			sp++
+		case compiler.STORE_NAME, compiler.STORE_GLOBAL:
+			sp--
+			vm.globals[code.Names[in.Arg()]] = stack[sp]
+		case compiler.LOAD_FAST:
+			v := locals[in.Arg()]
+			if v == nil {
+				exc = raise(object.Errorf(object.UnboundLocalError, "local variable '%s' referenced before assignment", code.Varnames[in.Arg()]), code, ip-1)
+				break
+			}
+			stack[sp] = v
+			sp++
+		case compiler.STORE_FAST:
+			sp--
+			locals[in.Arg()] = stack[sp]
+		case compiler.LOAD_DEREF:
+			v := cells[in.Arg()].Value
+			if v == nil {
+				exc = raise(unboundCell(code, in.Arg()), code, ip-1)
+				break
+			}
+			stack[sp] = v
+			sp++
+		case compiler.STORE_DEREF:
+			sp--
+			cells[in.Arg()].Value = stack[sp]
+		case compiler.DELETE_NAME, compiler.DELETE_GLOBAL:
+			name := code.Names[in.Arg()]
+			if _, ok := vm.globals[name]; !ok {
+				exc = raise(object.Errorf(object.NameError, "name '%s' is not defined", name), code, ip-1)
+				break
+			}
+			delete(vm.globals, name)
+		case compiler.DELETE_FAST:
+			if locals[in.Arg()] == nil {
+				exc = raise(object.Errorf(object.UnboundLocalError, "local variable '%s' referenced before assignment", code.Varnames[in.Arg()]), code, ip-1)
+				break
+			}
+			locals[in.Arg()] = nil
+		case compiler.DELETE_DEREF:
+			if cells[in.Arg()].Value == nil {
+				exc = raise(unboundCell(code, in.Arg()), code, ip-1)
+				break
+			}
+			cells[in.Arg()].Value = nil
+		case compiler.LOAD_CLOSURE:
+			stack[sp] = cells[in.Arg()]
+			sp++
+		case compiler.UNARY_OP:
+			v, err := object.UnaryOp(in.Arg(), stack[sp-1])
+			if err != nil {
+				exc = raise(err, code, ip-1)
+				break
+			}
+			stack[sp-1] = v
+		case compiler.BINARY_OP:
+			sp--
+			v, err := binaryOp(in.Arg(), stack[sp-1], stack[sp])
+			if err != nil {
+				exc = raise(err, code, ip-1)
+				break
+			}
+			stack[sp-1] = v
+		case compiler.COMPARE_OP:
+			sp--
+			v, err := compare(in.Arg(), stack[sp-1], stack[sp])
+			if err != nil {
+				exc = raise(err, code, ip-1)
+				break
+			}
+			stack[sp-1] = v
+		case compiler.BUILD_LIST:
+			n := in.Arg()
+			list := &object.List{Items: append([]object.Value(nil), stack[sp-n:sp]...)}
+			sp -= n
+			stack[sp] = list
+			sp++
+		case compiler.BUILD_MAP:
+			n := in.Arg()
+			d := &object.Dict{}
+			for i := sp - 2*n; i < sp; i += 2 {
+				if err := d.Set(stack[i], stack[i+1]); err != nil {
+					exc = raise(err, code, ip-1)
+					break
+				}
+			}
+			if exc != nil {
+				break
+			}
+			sp -= 2 * n
+			stack[sp] = d
+			sp++
+		case compiler.BUILD_TUPLE:
+			n := in.Arg()
+			t := &object.Tuple{Items: append([]object.Value(nil), stack[sp-n:sp]...)}
+			sp -= n
+			stack[sp] = t
+			sp++
+		case compiler.BUILD_SET:
+			n := in.Arg()
+			set := &object.Set{}
+			for _, v := range stack[sp-n : sp] {
+				if err := set.Add(v); err != nil {
+					exc = raise(err, code, ip-1)
+					break
+				}
+			}
+			if exc != nil {
+				break
+			}
+			sp -= n
+			stack[sp] = set
+			sp++
+		case compiler.LIST_APPEND:
+			sp--
+			list := stack[sp-1].(*object.List)
+			list.Items = append(list.Items, stack[sp])
+		case compiler.LIST_EXTEND:
+			sp--
+			items, err := object.StarredItems(stack[sp])
+			if err != nil {
+				exc = raise(err, code, ip-1)
+				break
+			}
+			list := stack[sp-1].(*object.List)
+			list.Items = append(list.Items, items...)
+		case compiler.SET_ADD:
+			sp--
+			if err := stack[sp-1].(*object.Set).Add(stack[sp]); err != nil {
+				exc = raise(err, code, ip-1)
+			}
+		case compiler.SET_UPDATE:
+			sp--
+			if err := stack[sp-1].(*object.Set).UpdateStarred(stack[sp]); err != nil {
+				exc = raise(err, code, ip-1)
+			}
+		case compiler.LIST_TO_TUPLE:
+			stack[sp-1] = &object.Tuple{Items: stack[sp-1].(*object.List).Items}
+		case compiler.UNPACK_SEQUENCE, compiler.UNPACK_EX:
+			before, after, starred := in.Arg(), 0, in.Op() == compiler.UNPACK_EX
+			if starred {
+				before, after = in.Arg()&0xff, in.Arg()>>8
+			}
+			items, err := object.Unpack(stack[sp-1], before, after, starred)
+			if err != nil {
+				exc = raise(err, code, ip-1)
+				break
+			}
+			// the first item end on top, it is stored first
+			sp--
+			for i := len(items) - 1; i >= 0; i-- {
+				stack[sp] = items[i]
+				sp++
+			}
+		case compiler.GET_ITER:
+			it, err := object.Iter(stack[sp-1])
+			if err != nil {
+				exc = raise(err, code, ip-1)
+				break
+			}
+			stack[sp-1] = it
+		case compiler.FOR_ITER:
+			item, ok, err := stack[sp-1].(object.Iterator).Next()
+			if err != nil {
+				exc = raise(err, code, ip-1)
+			} else if ok {
+				stack[sp] = item
+				sp++
+			} else {
+				sp--
+				ip = in.Arg()
+			}
+		case compiler.BUILD_SLICE:
+			s := &object.Slice{Start: stack[sp-in.Arg()], Stop: stack[sp-in.Arg()+1], Step: object.None}
+			if in.Arg() == 3 {
+				s.Step = stack[sp-1]
+			}
+			sp -= in.Arg()
+			stack[sp] = s
+			sp++
+		case compiler.BINARY_SUBSCR:
+			sp--
+			v, err := object.GetItem(stack[sp-1], stack[sp])
+			if err != nil {
+				exc = raise(err, code, ip-1)
+				break
+			}
+			stack[sp-1] = v
+		case compiler.STORE_SUBSCR:
+			sp -= 3
+			if err := object.SetItem(stack[sp+1], stack[sp+2], stack[sp]); err != nil {
+				exc = raise(err, code, ip-1)
+			}
+		case compiler.DELETE_SUBSCR:
+			sp -= 2
+			if err := object.DelItem(stack[sp], stack[sp+1]); err != nil {
+				exc = raise(err, code, ip-1)
+			}
+		case compiler.IMPORT_NAME:
+			v, err := vm.call(vm.Builtins["__import__"], []object.Value{object.Str(code.Names[in.Arg()])}, nil)
+			if err != nil {
+				exc = raise(err, code, ip-1)
+				break
+			}
+			stack[sp] = v
+			sp++
+		case compiler.LOAD_ATTR:
+			v, err := object.GetAttr(stack[sp-1], code.Names[in.Arg()])
+			if err != nil {
+				exc = raise(err, code, ip-1)
+				break
+			}
+			stack[sp-1] = v
+		case compiler.STORE_ATTR:
+			sp -= 2
+			if err := object.SetAttr(stack[sp+1], code.Names[in.Arg()], stack[sp]); err != nil {
+				exc = raise(err, code, ip-1)
+			}
+		case compiler.DELETE_ATTR:
+			sp--
+			if err := object.SetAttr(stack[sp], code.Names[in.Arg()], nil); err != nil {
+				exc = raise(err, code, ip-1)
+			}
+		case compiler.JUMP:
+			at := ip - 1
+			ip = in.Arg()
+			if vm.interrupted() {
+				exc = raise(&object.Exception{Class: object.KeyboardInterrupt}, code, at)
+			}
+		case compiler.POP_JUMP_IF_FALSE:
+			sp--
+			if !stack[sp].Truth() {
+				ip = in.Arg()
+			}
+		case compiler.ROT_TWO:
+			stack[sp-1], stack[sp-2] = stack[sp-2], stack[sp-1]
+		case compiler.ROT_THREE:
+			stack[sp-1], stack[sp-2], stack[sp-3] = stack[sp-2], stack[sp-3], stack[sp-1]
+		case compiler.JUMP_IF_TRUE_OR_POP:
+			if stack[sp-1].Truth() {
+				ip = in.Arg()
+			} else {
+				sp--
+			}
+		case compiler.JUMP_IF_FALSE_OR_POP:
+			if !stack[sp-1].Truth() {
+				ip = in.Arg()
+			} else {
+				sp--
+			}
+		case compiler.CALL:
+			argc := in.Arg()
+			v, err := vm.call(stack[sp-argc-1], stack[sp-argc:sp], nil)
+			if err != nil {
+				exc = raise(err, code, ip-1)
+				break
+			}
+			sp -= argc
+			stack[sp-1] = v
+		case compiler.CALL_KW:
+			names := stack[sp-1].(*object.Tuple).Items
+			argc := in.Arg()
+			sp--
+			kwnames := make([]string, len(names))
+			for i, name := range names {
+				kwnames[i] = string(name.(object.Str))
+			}
+			v, err := vm.call(stack[sp-argc-1], stack[sp-argc:sp], kwnames)
+			if err != nil {
+				exc = raise(err, code, ip-1)
+				break
+			}
+			sp -= argc
+			stack[sp-1] = v
+		case compiler.CALL_FUNCTION_EX:
+			var kwnames []string
+			var kwvalues []object.Value
+			if in.Arg() == 1 {
+				sp--
+				var err error
+				if kwnames, kwvalues, err = object.KeywordArgs(stack[sp].(*object.Dict)); err != nil {
+					exc = raise(err, code, ip-1)
+					break
+				}
+			}
+			sp--
+			args, err := object.StarArgs(stack[sp-1], stack[sp])
+			if err != nil {
+				exc = raise(err, code, ip-1)
+				break
+			}
+			v, err := vm.call(stack[sp-1], append(args, kwvalues...), kwnames)
+			if err != nil {
+				exc = raise(err, code, ip-1)
+				break
+			}
+			stack[sp-1] = v
+		case compiler.RETURN_VALUE:
+			return stack[sp-1], nil
+		case compiler.POP_TOP:
+			sp--
+		case compiler.PRINT_EXPR:
+			sp--
+			if stack[sp] != object.None {
+				io.WriteString(vm.Stdout, stack[sp].Repr()+"\n")
+			}
+		case compiler.DUP_TOP:
+			stack[sp] = stack[sp-1]
+			sp++
+		case compiler.SETUP_FINALLY:
+			blocks = append(blocks, block{handler: in.Arg(), sp: sp})
+		case compiler.POP_BLOCK:
+			blocks = blocks[:len(blocks)-1]
+		case compiler.PUSH_EXC_INFO:
+			vm.handling = append(vm.handling, stack[sp-1].(*object.Exception))
+		case compiler.POP_EXCEPT:
+			vm.handling = vm.handling[:len(vm.handling)-1]
+		case compiler.JUMP_IF_NOT_EXC_MATCH:
+			sp -= 2
+			match, err := stack[sp].(*object.Exception).Matches(stack[sp+1])
+			if err != nil {
+				exc = raise(err, code, ip-1)
+				break
+			}
+			if !match {
+				ip = in.Arg()
+			}
+		case compiler.RAISE_VARARGS:
+			exc = vm.raiseVarargs(stack[sp-in.Arg():sp], code, ip-1)
+			sp -= in.Arg()
+		case compiler.RERAISE:
+			sp--
+			exc = stack[sp].(*object.Exception)
+		case compiler.DICT_MERGE:
+			sp--
+			if err := object.MergeKwargs(stack[sp-1-in.Arg()], stack[sp-1].(*object.Dict), stack[sp]); err != nil {
+				exc = raise(err, code, ip-1)
+			}
+		case compiler.SET_DEFAULTS:
+			sp--
+			fn := stack[sp].(*Function)
+			if in.Arg() == 1 {
+				fn.Defaults = stack[sp-1].(*object.Tuple).Items
+			} else {
+				fn.KwDefaults = map[string]object.Value{}
+				names, values, _ := object.KeywordArgs(stack[sp-1].(*object.Dict))
+				for i, name := range names {
+					fn.KwDefaults[name] = values[i]
+				}
+			}
+			stack[sp-1] = fn
+		case compiler.MAKE_FUNCTION:
+			n := in.Arg()
+			fn := &Function{Code: stack[sp-1].(*compiler.Code), vm: vm}
+			for _, c := range stack[sp-n-1 : sp-1] {
+				fn.Cells = append(fn.Cells, c.(*Cell))
+			}
+			sp -= n
+			stack[sp-1] = fn
+		default:
+			exc = raise(object.Errorf(object.SystemError, "unknown opcode %s", in.Op()), code, ip-1)
+		}
+
+		// jump to the handler of the innermost block, or leave the function
+		if exc != nil {
+			exc.SetContext(vm.handledException())
+			if len(blocks) == 0 {
+				return nil, exc
+			}
+			b := blocks[len(blocks)-1]
+			blocks = blocks[:len(blocks)-1]
+			sp = b.sp
+			stack[sp] = exc
+			sp++
+			ip = b.handler
+		}
+	}
+	return object.None, nil
+}
+
+// exception raised by the raise statement, args are the exception and the
+// cause, none for a bare raise of the handled exception
+func (vm *VM) raiseVarargs(args []object.Value, code *compiler.Code, ip int) *object.Exception {
+	if len(args) == 0 {
+		if exc := vm.handledException(); exc != nil {
+			return exc
+		}
+		return raise(object.Errorf(object.RuntimeError, "No active exception to reraise"), code, ip)
+	}
+	exc, err := object.NewException(args[0])
+	if err != nil {
+		return raise(err, code, ip)
+	}
+	if len(args) == 2 {
+		if err := exc.SetCause(args[1]); err != nil {
+			return raise(err, code, ip)
+		}
+	}
+	return raise(exc, code, ip)
+}
+
+// call a function object with its arguments
+func (vm *VM) call(v object.Value, args []object.Value, kwnames []string) (object.Value, error) {
+	n := len(args) - len(kwnames)
+	switch f := v.(type) {
+	case *object.Builtin:
+		// args is a slice of the stack, builtins get their own copy
+		return f.Call(append([]object.Value(nil), args[:n]...), object.KeywordMap(kwnames, args[n:]))
+	case *object.Type:
+		return object.Instantiate(f, args[:n], object.KeywordMap(kwnames, args[n:]))
+	case *object.Method:
+		return f.Call(append([]object.Value(nil), args[:n]...), object.KeywordMap(kwnames, args[n:]))
+	}
+	fn, ok := v.(*Function)
+	if !ok {
+		return nil, object.Errorf(object.TypeError, "'%s' object is not callable", v.Type().Name)
+	}
+	code := fn.Code
+	args, exc := code.Params.Bind(code.Name, args, kwnames, fn.Defaults, fn.KwDefaults)
+	if exc != nil {
+		return nil, exc
+	}
+	if vm.interrupted() {
+		return nil, &object.Exception{Class: object.KeyboardInterrupt}
+	}
+	if vm.depth+1 >= maxDepth {
+		return nil, object.Errorf(object.RecursionError, "maximum recursion depth exceeded")
+	}
+	locals := make([]object.Value, len(code.Varnames))
+	copy(locals, args)
+	vm.depth++
+	defer func() { vm.depth-- }()
+	return vm.run(code, locals, fn.Cells)
+}
+
+// fast path for int addition and subtraction that do not overflow,
+// everything else go through object.BinaryOp
+func binaryOp(op int, left, right object.Value) (object.Value, error) {
+	if li, ok := left.(object.Int); ok {
+		if ri, ok := right.(object.Int); ok {
+			switch op {
+			case PLUS:
+				if s := li + ri; (s > li) == (ri > 0) {
+					return s, nil
+				}
+			case MINUS:
+				if d := li - ri; (d < li) == (ri > 0) {
+					return d, nil
+				}
+			}
+		}
+	}
+	return object.BinaryOp(op, left, right)
+}
+
+// fast path for int comparison, everything else go through object.Compare
+func compare(op int, left, right object.Value) (object.Value, error) {
+	if li, ok := left.(object.Int); ok {
+		if ri, ok := right.(object.Int); ok {
+			switch op {
+			case LESSTHAN:
+				return object.Bool(li < ri), nil
+			case LESSEQUAL:
+				return object.Bool(li <= ri), nil
+			case GREATERTHAN:
+				return object.Bool(li > ri), nil
+			case GREATEREQUAL:
+				return object.Bool(li >= ri), nil
+			case EQUAL:
+				return object.Bool(li == ri), nil
+			case NOTEQUAL:
+				return object.Bool(li != ri), nil
+			}
+		}
+	}
+	return object.Compare(op, left, right)
+}